@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+	"wallet-service/config"
+	"wallet-service/internal/ledger"
+	"wallet-service/internal/wallet"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// reconcile checks every wallet balance against its journal account and
+// prints the report as JSON. It exits with status 1 when mismatches remain.
+func main() {
+	repair := flag.Bool("repair", false, "reset mismatched balances to the journal value and record an audit entry")
+	timeout := flag.Duration("timeout", 10*time.Minute, "maximum duration of the run")
+	flag.Parse()
+
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	cfg := config.LoadConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	if err := mongoClient.Ping(ctx, readpref.Primary()); err != nil {
+		log.Fatalf("Failed to ping MongoDB: %v", err)
+	}
+
+	db := mongoClient.Database(cfg.MongoDB)
+	walletRepository := wallet.NewWalletRepository(
+		db.Collection("wallets"),
+		db.Collection("transactions"),
+		db.Collection("idempotency_keys"),
+		db.Collection("reconciliation_audits"),
+		db.Collection("order_charges"),
+		db.Collection("holds"),
+		db.Collection("transfer_totals"),
+	)
+
+	ledgerService := ledger.NewLedgerService(ledger.NewLedgerRepository(db.Collection("journal_entries")))
+
+	report, err := wallet.NewReconcileService(walletRepository, ledgerService).Reconcile(ctx, *repair)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	if len(report.Mismatches) > report.Repaired {
+		os.Exit(1)
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"time"
 	"wallet-service/config"
 	"wallet-service/internal/exchange"
+	"wallet-service/internal/ledger"
+	"wallet-service/internal/middleware"
 	"wallet-service/internal/user"
 	"wallet-service/internal/wallet"
+	"wallet-service/internal/wallettype"
 	"wallet-service/pkg/consul"
 	"wallet-service/pkg/zap"
 
@@ -52,22 +55,120 @@ func main() {
 		}
 	}()
 
+	if err := middleware.Configure(middleware.AuthConfig{
+		HMACSecret:    cfg.JWTSecret,
+		PublicKeyFile: cfg.JWTPublicKeyFile,
+		JWKSSource:    cfg.JWTJWKSSource,
+		Issuer:        cfg.JWTIssuer,
+		Audience:      cfg.JWTAudience,
+		Leeway:        cfg.JWTLeeway,
+	}); err != nil {
+		logger.Fatalf("Failed to configure token verification: %v", err)
+	}
+	if cfg.ServiceJWTSecret != "" || cfg.ServiceJWTPublicKeyFile != "" || cfg.ServiceJWTJWKSSource != "" {
+		// Service callers have no user token to forward to the main service
+		if cfg.MainServiceToken == "" {
+			logger.Fatalf("MAIN_SERVICE_TOKEN is required with service authentication")
+		}
+		if err := middleware.ConfigureServices(middleware.AuthConfig{
+			HMACSecret:    cfg.ServiceJWTSecret,
+			PublicKeyFile: cfg.ServiceJWTPublicKeyFile,
+			JWKSSource:    cfg.ServiceJWTJWKSSource,
+			Issuer:        cfg.ServiceJWTIssuer,
+			Audience:      cfg.ServiceJWTAudience,
+			Leeway:        cfg.JWTLeeway,
+		}, cfg.ServiceAllowlist); err != nil {
+			logger.Fatalf("Failed to configure service authentication: %v", err)
+		}
+	}
+	middleware.SetAuditLogger(logger)
+
 	// Set up router with Gin
 	router := gin.Default()
-	userService := user.NewUserService(consulClient)
+	userService := user.NewUserService(consulClient, cfg.MainServiceToken)
+	cachedUserService := user.NewCachedUserService(userService, cfg.UserCacheTTL, cfg.UserCacheNegativeTTL)
+	// Roles are never served from the cache, so a revoked role stops working at once
+	middleware.SetRoleResolver(func(ctx context.Context, userID string) ([]string, error) {
+		userInfor, err := userService.GetUserInfor(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return []string{userInfor.Role}, nil
+	})
 
 	exchangeRateCollection := mongoClient.Database(cfg.MongoDB).Collection("exchange_rates")
 	exchangeRepository := exchange.NewExchangeRepository(exchangeRateCollection)
+	if err := exchangeRepository.MigrateMoneyFields(context.Background()); err != nil {
+		logger.Fatalf("Failed to migrate exchange rates: %v", err)
+	}
+	if err := exchangeRepository.MigrateQuoteCurrency(context.Background(), cfg.WalletCurrency); err != nil {
+		logger.Fatalf("Failed to migrate exchange rate pairs: %v", err)
+	}
+	if err := exchangeRepository.MigrateEffectiveDates(context.Background()); err != nil {
+		logger.Fatalf("Failed to migrate exchange rate versions: %v", err)
+	}
+	if err := exchangeRepository.EnsureIndexes(context.Background()); err != nil {
+		logger.Fatalf("Failed to create exchange rate indexes: %v", err)
+	}
 	exchangeService := exchange.NewExchangeService(exchangeRepository)
 	exchangeHandler := exchange.NewExchangeHandler(exchangeService)
 	
+	journalCollection := mongoClient.Database(cfg.MongoDB).Collection("journal_entries")
+	ledgerRepository := ledger.NewLedgerRepository(journalCollection)
+	if err := ledgerRepository.EnsureIndexes(context.Background()); err != nil {
+		logger.Fatalf("Failed to create ledger indexes: %v", err)
+	}
+	ledgerService := ledger.NewLedgerService(ledgerRepository)
+	ledgerHandler := ledger.NewLedgerHandler(ledgerService)
+
+	walletTypeCollection := mongoClient.Database(cfg.MongoDB).Collection("wallet_types")
+	walletTypeRepository := wallettype.NewWalletTypeRepository(walletTypeCollection)
+	if err := walletTypeRepository.EnsureIndexes(context.Background()); err != nil {
+		logger.Fatalf("Failed to create wallet type indexes: %v", err)
+	}
+	if err := walletTypeRepository.SeedWalletTypes(context.Background(), wallettype.Defaults(cfg.WalletCurrency)); err != nil {
+		logger.Fatalf("Failed to seed wallet types: %v", err)
+	}
+	walletTypeService := wallettype.NewWalletTypeService(walletTypeRepository)
+	walletTypeHandler := wallettype.NewWalletTypeHandler(walletTypeService)
+
 	walletCollection := mongoClient.Database(cfg.MongoDB).Collection("wallets")
 	transactionCollection := mongoClient.Database(cfg.MongoDB).Collection("transactions")
-	walletRepository := wallet.NewWalletRepository(walletCollection, transactionCollection)
-	walletService := wallet.NewWalletService(walletRepository, exchangeService, userService)
+	idempotencyCollection := mongoClient.Database(cfg.MongoDB).Collection("idempotency_keys")
+	auditCollection := mongoClient.Database(cfg.MongoDB).Collection("reconciliation_audits")
+	orderCollection := mongoClient.Database(cfg.MongoDB).Collection("order_charges")
+	holdCollection := mongoClient.Database(cfg.MongoDB).Collection("holds")
+	transferTotalCollection := mongoClient.Database(cfg.MongoDB).Collection("transfer_totals")
+	walletRepository := wallet.NewWalletRepository(walletCollection, transactionCollection, idempotencyCollection, auditCollection, orderCollection, holdCollection, transferTotalCollection)
+	if err := walletRepository.MigrateMoneyFields(context.Background()); err != nil {
+		logger.Fatalf("Failed to migrate wallet balances: %v", err)
+	}
+	// The TTL index counts whole seconds; anything shorter would expire
+	// keys as soon as they are written.
+	if cfg.IdempotencyTTL < time.Second {
+		logger.Fatalf("IDEMPOTENCY_TTL must be at least 1s, got %s", cfg.IdempotencyTTL)
+	}
+	if err := walletRepository.EnsureIndexes(context.Background(), cfg.IdempotencyTTL); err != nil {
+		logger.Fatalf("Failed to create wallet indexes: %v", err)
+	}
+	walletService := wallet.NewWalletService(walletRepository, exchangeService, walletTypeService, cachedUserService, ledgerService, wallet.Settings{
+		TransferMaxAmount:  cfg.TransferMaxAmount,
+		TransferDailyLimit: cfg.TransferDailyLimit,
+		InternalTransfers:  cfg.InternalTransfers,
+		DegradedUserCheck:  cfg.UserCheckDegraded,
+	})
+	if err := walletService.MigrateCurrencies(context.Background()); err != nil {
+		logger.Fatalf("Failed to migrate wallet currencies: %v", err)
+	}
+	if err := walletService.MigrateOpeningBalances(context.Background()); err != nil {
+		logger.Fatalf("Failed to post opening balances: %v", err)
+	}
 	walletHandler := wallet.NewWalletHandler(walletService)
+	reconcileService := wallet.NewReconcileService(walletRepository, ledgerService)
 	wallet.RegisterRoutes(router, walletHandler)
 	exchange.RegisterRoutes(router, exchangeHandler)
+	ledger.RegisterRoutes(router, ledgerHandler)
+	wallettype.RegisterRoutes(router, walletTypeHandler)
 
 	// Initialize HTTP server
 	server := &http.Server{	
@@ -83,6 +184,24 @@ func main() {
 		}
 	}()
 
+	// Run periodic wallet reconciliation if configured
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.ReconcileInterval > 0 {
+		go runReconciliation(jobCtx, logger, reconcileService, cfg.ReconcileInterval, cfg.ReconcileRepair)
+	}
+	if cfg.HoldExpiryInterval > 0 {
+		go runHoldExpiry(jobCtx, logger, walletService, cfg.HoldExpiryInterval)
+	}
+	if cfg.RateProvider != "" && cfg.RateSyncInterval > 0 {
+		provider, err := exchange.NewProvider(cfg.RateProvider, cfg.RateSource)
+		if err != nil {
+			logger.Fatalf("Failed to set up rate provider: %v", err)
+		}
+		rateSync := exchange.NewRateSync(exchangeService, provider, cfg.RateMaxChangePercent)
+		go runRateSync(jobCtx, logger, rateSync, cfg.RateSyncInterval)
+	}
+
 	// Set up graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -116,3 +235,73 @@ func connectToMongoDB(uri string) (*mongo.Client, error) {
 	log.Println("Successfully connected to MongoDB")
 	return client, nil
 }
+
+func runReconciliation(ctx context.Context, logger zap.Logger, reconcileService wallet.ReconcileService, interval time.Duration, repair bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := reconcileService.Reconcile(ctx, repair)
+			if err != nil {
+				logger.Errorf("Reconciliation failed: %v", err)
+				continue
+			}
+			if len(report.Mismatches) > 0 {
+				logger.Warnf("Reconciliation %s: %d of %d wallets mismatched, %d repaired",
+					report.RunID, len(report.Mismatches), report.WalletsChecked, report.Repaired)
+				continue
+			}
+			logger.Infof("Reconciliation %s: %d wallets match the ledger", report.RunID, report.WalletsChecked)
+		}
+	}
+}
+
+func runHoldExpiry(ctx context.Context, logger zap.Logger, walletService wallet.WalletService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := walletService.ExpireHolds(ctx)
+			if err != nil {
+				logger.Errorf("Hold expiry failed: %v", err)
+			}
+			if expired > 0 {
+				logger.Infof("Expired %d stale holds", expired)
+			}
+		}
+	}
+}
+
+func runRateSync(ctx context.Context, logger zap.Logger, rateSync *exchange.RateSync, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Sync once at start so a fresh deployment has rates straight away
+	for {
+		report, err := rateSync.Sync(ctx)
+		if err != nil {
+			logger.Errorf("Rate sync failed: %v", err)
+		} else {
+			for _, rejected := range report.Rejected {
+				logger.Warnf("Rate sync from %s rejected %s/%s at %s: %s",
+					report.Provider, rejected.Currency, rejected.QuoteCurrency, rejected.Rate, rejected.Reason)
+			}
+			logger.Infof("Rate sync from %s: %d updated, %d unchanged, %d rejected",
+				report.Provider, report.Updated, report.Unchanged, len(report.Rejected))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
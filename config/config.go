@@ -1,6 +1,13 @@
 package config
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-service/pkg/money"
+)
 
 type Consul struct {
 	Host string `mapstructure:"host" validate:"required"`
@@ -51,17 +58,102 @@ type Config struct {
 	Port     string
 	MongoURI string
 	MongoDB  string
-	Consul   Consul           `mapstructure:"consul" validate:"required"`
-	Registry Registry         `mapstructure:"registry" validate:"required"`
-	App      AppConfiguration `mapstructure:"app"`
-	Zap      ZapConfig        `mapstructure:"zap"`
+	// IdempotencyTTL is how long an Idempotency-Key can be replayed.
+	IdempotencyTTL time.Duration
+	// ReconcileInterval runs wallet reconciliation periodically in the
+	// server when non-zero; ReconcileRepair lets that job fix mismatches.
+	ReconcileInterval time.Duration
+	ReconcileRepair   bool
+	// HoldExpiryInterval is how often stale holds are released.
+	HoldExpiryInterval time.Duration
+	// TransferMaxAmount and TransferDailyLimit bound user-to-user
+	// transfers; zero disables a limit.
+	TransferMaxAmount  money.Amount
+	TransferDailyLimit money.Amount
+	// InternalTransfers lists the "from:to" wallet type pairs a user may
+	// move funds between, e.g. "service:store".
+	InternalTransfers []string
+	// WalletCurrency is the currency of the wallet types seeded into an
+	// empty catalog, and the quote currency given to rates created before
+	// rates had currency pairs.
+	WalletCurrency string
+	// RateProvider ("file" or "http") and RateSource choose where rates
+	// are synced from every RateSyncInterval; an empty provider or a zero
+	// interval disables syncing. A quote moving more than
+	// RateMaxChangePercent from the current rate is rejected.
+	RateProvider         string
+	RateSource           string
+	RateSyncInterval     time.Duration
+	RateMaxChangePercent float64
+	// JWTSecret, JWTPublicKeyFile and JWTJWKSSource (a URL or path) are
+	// the keys bearer tokens are verified with; JWTIssuer and JWTAudience
+	// are checked when set, allowing JWTLeeway of clock skew.
+	JWTSecret        string
+	JWTPublicKeyFile string
+	JWTJWKSSource    string
+	JWTIssuer        string
+	JWTAudience      string
+	JWTLeeway        time.Duration
+	// ServiceJWTSecret, ServiceJWTPublicKeyFile and ServiceJWTJWKSSource
+	// verify the tokens internal services send in X-Service-Authorization;
+	// none set disables service authentication, and any set requires
+	// ServiceJWTIssuer and MainServiceToken. ServiceAllowlist entries
+	// read "name=scope scope" and name the services accepted and the
+	// scopes each may use.
+	ServiceJWTSecret        string
+	ServiceJWTPublicKeyFile string
+	ServiceJWTJWKSSource    string
+	ServiceJWTIssuer        string
+	ServiceJWTAudience      string
+	ServiceAllowlist        []string
+	// MainServiceToken is this service's own credential for calls to the
+	// main service; when empty the caller's token is forwarded.
+	MainServiceToken string
+	// UserCacheTTL and UserCacheNegativeTTL are how long users found and not
+	// found are remembered; UserCheckDegraded lets users with wallets
+	// through while the main service is down.
+	UserCacheTTL         time.Duration
+	UserCacheNegativeTTL time.Duration
+	UserCheckDegraded    bool
+	Consul               Consul           `mapstructure:"consul" validate:"required"`
+	Registry             Registry         `mapstructure:"registry" validate:"required"`
+	App                  AppConfiguration `mapstructure:"app"`
+	Zap                  ZapConfig        `mapstructure:"zap"`
 }
 
 func LoadConfig() *Config {
 	config := &Config{
-		Port:     getEnv("PORT", "8009"),
-		MongoURI: getEnv("MONGO_URI", "mongodb://localhost:27013"),
-		MongoDB:  getEnv("MONGO_DB", "wallet-service"),
+		Port:                    getEnv("PORT", "8009"),
+		MongoURI:                getEnv("MONGO_URI", "mongodb://localhost:27013"),
+		MongoDB:                 getEnv("MONGO_DB", "wallet-service"),
+		IdempotencyTTL:          getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		ReconcileInterval:       getEnvDuration("RECONCILE_INTERVAL", 0),
+		ReconcileRepair:         getEnvBool("RECONCILE_REPAIR", false),
+		HoldExpiryInterval:      getEnvDuration("HOLD_EXPIRY_INTERVAL", time.Minute),
+		TransferMaxAmount:       getEnvAmount("TRANSFER_MAX_AMOUNT", 0),
+		TransferDailyLimit:      getEnvAmount("TRANSFER_DAILY_LIMIT", 0),
+		InternalTransfers:       getEnvList("INTERNAL_TRANSFERS", []string{"service:store"}),
+		WalletCurrency:          getEnv("WALLET_CURRENCY", "POINT"),
+		RateProvider:            getEnv("RATE_PROVIDER", ""),
+		RateSource:              getEnv("RATE_SOURCE", ""),
+		RateSyncInterval:        getEnvDuration("RATE_SYNC_INTERVAL", time.Hour),
+		RateMaxChangePercent:    getEnvFloat("RATE_MAX_CHANGE_PERCENT", 10),
+		JWTSecret:               getEnv("JWT_SECRET", ""),
+		JWTPublicKeyFile:        getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTJWKSSource:           getEnv("JWT_JWKS_SOURCE", ""),
+		JWTIssuer:               getEnv("JWT_ISSUER", ""),
+		JWTAudience:             getEnv("JWT_AUDIENCE", ""),
+		JWTLeeway:               getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		ServiceJWTSecret:        getEnv("SERVICE_JWT_SECRET", ""),
+		ServiceJWTPublicKeyFile: getEnv("SERVICE_JWT_PUBLIC_KEY_FILE", ""),
+		ServiceJWTJWKSSource:    getEnv("SERVICE_JWT_JWKS_SOURCE", ""),
+		ServiceJWTIssuer:        getEnv("SERVICE_JWT_ISSUER", ""),
+		ServiceJWTAudience:      getEnv("SERVICE_JWT_AUDIENCE", "wallet-service"),
+		ServiceAllowlist:        getEnvList("SERVICE_ALLOWLIST", nil),
+		MainServiceToken:        getEnv("MAIN_SERVICE_TOKEN", ""),
+		UserCacheTTL:            getEnvDuration("USER_CACHE_TTL", 5*time.Minute),
+		UserCacheNegativeTTL:    getEnvDuration("USER_CACHE_NEGATIVE_TTL", 30*time.Second),
+		UserCheckDegraded:       getEnvBool("USER_CHECK_DEGRADED", false),
 		Consul: Consul{
 			Host: getEnv("CONSUL_HOST", "localhost"),
 			Port: getEnv("CONSUL_PORT", "8500"),
@@ -108,3 +200,57 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAmount fails startup on a malformed amount rather than lifting a limit.
+func getEnvAmount(key string, defaultValue money.Amount) money.Amount {
+	if value, exists := os.LookupEnv(key); exists {
+		a, err := money.ParseAmount(value)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: %v", key, value, err)
+		}
+		return a
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -5,8 +5,21 @@ import (
 )
 
 const (
-	ErrInvalidOperation = "ERR_INVALID_OPERATION"
-	ErrInvalidRequest   = "ERR_INVALID_REQUEST"
+	ErrInvalidOperation  = "ERR_INVALID_OPERATION"
+	ErrInvalidRequest    = "ERR_INVALID_REQUEST"
+	ErrInsufficientFunds = "ERR_INSUFFICIENT_FUNDS"
+
+	ErrIdempotencyConflict = "ERR_IDEMPOTENCY_CONFLICT"
+	ErrRefundExceedsCharge = "ERR_REFUND_EXCEEDS_CHARGE"
+	ErrOrderAlreadyCharged = "ERR_ORDER_ALREADY_CHARGED"
+	ErrHoldNotActive       = "ERR_HOLD_NOT_ACTIVE"
+	ErrTransferLimit       = "ERR_TRANSFER_LIMIT"
+	ErrRateNotFound        = "ERR_RATE_NOT_FOUND"
+	ErrRateVersionExists   = "ERR_RATE_VERSION_EXISTS"
+	ErrUnauthorized        = "ERR_UNAUTHORIZED"
+	ErrForbidden           = "ERR_FORBIDDEN"
+	ErrNotFound            = "ERR_NOT_FOUND"
+	ErrServiceUnavailable  = "ERR_SERVICE_UNAVAILABLE"
 )
 
 type APIResponse struct {
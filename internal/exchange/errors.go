@@ -0,0 +1,11 @@
+package exchange
+
+import "errors"
+
+// ErrRateNotFound is returned when no rate converts between two currencies
+// in either direction at the time asked for.
+var ErrRateNotFound = errors.New("no exchange rate in effect")
+
+// ErrRateVersionExists is returned when a version of the same currency pair
+// already takes effect at the same time.
+var ErrRateVersionExists = errors.New("a rate version already takes effect at that time for this currency pair")
@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"wallet-service/pkg/money"
+)
+
+// fileProvider reads rates from a local file on every fetch, so the file
+// can be edited while the service runs. It stands in for a real provider
+// offline and in tests.
+//
+// A .json file holds an array of quotes:
+//
+//	[{"currency": "VND", "quote_currency": "POINT", "rate": 0.001}]
+//
+// A .csv file has a currency,quote_currency,rate header row.
+type fileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) ExchangeRateProvider {
+	return &fileProvider{
+		path: path,
+	}
+}
+
+func (p *fileProvider) Name() string {
+	return "file:" + p.path
+}
+
+func (p *fileProvider) FetchRates(ctx context.Context) ([]Quote, error) {
+
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(p.path)) {
+	case ".json":
+		return decodeJSONQuotes(file)
+	case ".csv":
+		return decodeCSVQuotes(file)
+	default:
+		return nil, fmt.Errorf("rate file %s must be .json or .csv", p.path)
+	}
+}
+
+func decodeJSONQuotes(r io.Reader) ([]Quote, error) {
+
+	var quotes []Quote
+
+	if err := json.NewDecoder(r).Decode(&quotes); err != nil {
+		return nil, fmt.Errorf("invalid rate JSON: %w", err)
+	}
+
+	return quotes, nil
+}
+
+func decodeCSVQuotes(r io.Reader) ([]Quote, error) {
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate CSV: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"currency", "quote_currency", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("rate CSV is missing the %s column", name)
+		}
+	}
+
+	var quotes []Quote
+	for line, row := range rows[1:] {
+		rate, err := money.ParseRate(row[columns["rate"]])
+		if err != nil {
+			return nil, fmt.Errorf("rate CSV line %d: %w", line+2, err)
+		}
+
+		quotes = append(quotes, Quote{
+			Currency:      strings.TrimSpace(row[columns["currency"]]),
+			QuoteCurrency: strings.TrimSpace(row[columns["quote_currency"]]),
+			Rate:          rate,
+		})
+	}
+
+	return quotes, nil
+}
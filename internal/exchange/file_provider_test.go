@@ -0,0 +1,152 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"wallet-service/pkg/money"
+)
+
+func TestDecodeJSONQuotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []Quote
+		wantErr bool
+	}{
+		{
+			name: "numbers and strings",
+			body: `[{"currency": "VND", "quote_currency": "POINT", "rate": 0.001},
+				{"currency": "USD", "quote_currency": "POINT", "rate": "25.5"}]`,
+			want: []Quote{
+				{Currency: "VND", QuoteCurrency: "POINT", Rate: 1000},
+				{Currency: "USD", QuoteCurrency: "POINT", Rate: 25500000},
+			},
+		},
+		{
+			name: "currency not known to the service is passed on",
+			body: `[{"currency": "XYZ", "quote_currency": "POINT", "rate": 2}]`,
+			want: []Quote{{Currency: "XYZ", QuoteCurrency: "POINT", Rate: 2000000}},
+		},
+		{
+			name: "empty array",
+			body: `[]`,
+			want: nil,
+		},
+		{name: "not an array", body: `{"currency": "VND"}`, wantErr: true},
+		{name: "truncated", body: `[{"currency": "VND"`, wantErr: true},
+		{name: "malformed rate", body: `[{"currency": "VND", "quote_currency": "POINT", "rate": "abc"}]`, wantErr: true},
+		{name: "rate with too many decimals", body: `[{"currency": "VND", "quote_currency": "POINT", "rate": 0.0000001}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeJSONQuotes(strings.NewReader(tt.body))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeJSONQuotes = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeJSONQuotes: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeJSONQuotes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCSVQuotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []Quote
+		wantErr bool
+	}{
+		{
+			name: "header order and spacing",
+			body: "rate, currency ,quote_currency\n0.001, VND ,POINT\n25.5,USD,POINT\n",
+			want: []Quote{
+				{Currency: "VND", QuoteCurrency: "POINT", Rate: 1000},
+				{Currency: "USD", QuoteCurrency: "POINT", Rate: 25500000},
+			},
+		},
+		{
+			name: "currency not known to the service is passed on",
+			body: "currency,quote_currency,rate\nXYZ,POINT,2\n",
+			want: []Quote{{Currency: "XYZ", QuoteCurrency: "POINT", Rate: 2000000}},
+		},
+		{name: "empty file", body: ""},
+		{name: "header only", body: "currency,quote_currency,rate\n"},
+		{name: "missing rate column", body: "currency,quote_currency\nVND,POINT\n", wantErr: true},
+		{name: "row with too few fields", body: "currency,quote_currency,rate\nVND,POINT\n", wantErr: true},
+		{name: "row with too many fields", body: "currency,quote_currency,rate\nVND,POINT,0.001,extra\n", wantErr: true},
+		{name: "malformed rate", body: "currency,quote_currency,rate\nVND,POINT,abc\n", wantErr: true},
+		{name: "empty rate", body: "currency,quote_currency,rate\nVND,POINT,\n", wantErr: true},
+		{name: "unterminated quote", body: "currency,quote_currency,rate\n\"VND,POINT,0.001\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCSVQuotes(strings.NewReader(tt.body))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeCSVQuotes = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCSVQuotes: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCSVQuotes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderPicksFormatByExtension(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+
+	want := []Quote{{Currency: "VND", QuoteCurrency: "POINT", Rate: money.Rate(1000)}}
+
+	for _, path := range []string{
+		write("rates.json", `[{"currency": "VND", "quote_currency": "POINT", "rate": 0.001}]`),
+		write("rates.CSV", "currency,quote_currency,rate\nVND,POINT,0.001\n"),
+	} {
+		got, err := NewFileProvider(path).FetchRates(context.Background())
+		if err != nil {
+			t.Fatalf("FetchRates(%s): %v", path, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FetchRates(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	if _, err := NewFileProvider(write("rates.txt", "VND POINT 0.001")).FetchRates(context.Background()); err == nil {
+		t.Error("FetchRates accepted a .txt file")
+	}
+	if _, err := NewFileProvider(filepath.Join(dir, "missing.json")).FetchRates(context.Background()); err == nil {
+		t.Error("FetchRates accepted a missing file")
+	}
+}
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"errors"
 	"net/http"
 	"wallet-service/helper"
 
@@ -26,13 +27,13 @@ func (h *ExchangeHandler) CreateExchangeRate(c *gin.Context) {
 		return
 	}
 
-	err := h.exchangeService.CreateExchangeRate(c, &req)
+	exchange, err := h.exchangeService.CreateExchangeRate(c, &req)
 	if err != nil {
-		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		sendExchangeError(c, err)
 		return
 	}
 
-	helper.SendSuccess(c, http.StatusOK, "success", nil)
+	helper.SendSuccess(c, http.StatusOK, "success", exchange)
 
 }
 
@@ -62,6 +63,20 @@ func (h *ExchangeHandler) GetExchangeRate(c *gin.Context) {
 
 }
 
+func (h *ExchangeHandler) GetRateHistory(c *gin.Context) {
+
+	currency := c.Param("currency")
+
+	history, err := h.exchangeService.GetRateHistory(c, currency, c.Query("quote_currency"))
+	if err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", history)
+
+}
+
 func (h *ExchangeHandler) UpdateExchangeRate(c *gin.Context) {
 	
 	id := c.Param("id")
@@ -73,13 +88,13 @@ func (h *ExchangeHandler) UpdateExchangeRate(c *gin.Context) {
 		return
 	}
 
-	err := h.exchangeService.UpdateExchangeRate(c, id, &req)
+	exchange, err := h.exchangeService.UpdateExchangeRate(c, id, &req)
 	if err != nil {
-		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		sendExchangeError(c, err)
 		return
 	}
 
-	helper.SendSuccess(c, http.StatusOK, "success", nil)
+	helper.SendSuccess(c, http.StatusOK, "success", exchange)
 
 }
 
@@ -95,4 +110,13 @@ func (h *ExchangeHandler) DeleteExchangeRate(c *gin.Context) {
 
 	helper.SendSuccess(c, http.StatusOK, "success", nil)
 
-}
\ No newline at end of file
+}
+
+func sendExchangeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrRateVersionExists):
+		helper.SendError(c, http.StatusConflict, err, helper.ErrRateVersionExists)
+	default:
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+	}
+}
@@ -0,0 +1,46 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// httpProvider fetches quotes from a URL serving the same JSON array the
+// file provider reads.
+type httpProvider struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPProvider(url string) ExchangeRateProvider {
+	return &httpProvider{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *httpProvider) Name() string {
+	return p.url
+}
+
+func (p *httpProvider) FetchRates(ctx context.Context) ([]Quote, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rate provider returned status %d", resp.StatusCode)
+	}
+
+	return decodeJSONQuotes(resp.Body)
+}
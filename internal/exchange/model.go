@@ -2,16 +2,23 @@ package exchange
 
 import (
 	"time"
+	"wallet-service/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ExchangeRate is one immutable version of a currency pair's rate: one unit
+// of Currency is worth Rate units of QuoteCurrency from EffectiveFrom until
+// EffectiveTo. Versions of a pair never overlap; the latest has no
+// EffectiveTo. A new rate is a new version, so every deposit can point at
+// the exact version it was converted with.
 type ExchangeRate struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Currency  string             `bson:"currency" json:"currency"`
-	Rate      float64            `bson:"rate" json:"rate"`
-	Active    bool               `bson:"active" json:"active"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Currency      string             `bson:"currency" json:"currency"`
+	QuoteCurrency string             `bson:"quote_currency" json:"quote_currency"`
+	Rate          money.Rate         `bson:"rate" json:"rate"`
+	EffectiveFrom time.Time          `bson:"effective_from" json:"effective_from"`
+	EffectiveTo   *time.Time         `bson:"effective_to,omitempty" json:"effective_to,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
 }
-
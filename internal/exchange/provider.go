@@ -0,0 +1,39 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"wallet-service/pkg/money"
+)
+
+// Quote is one currency pair's rate as reported by a provider.
+type Quote struct {
+	Currency      string     `json:"currency"`
+	QuoteCurrency string     `json:"quote_currency"`
+	Rate          money.Rate `json:"rate"`
+}
+
+// ExchangeRateProvider is a source of rates outside the service. RateSync
+// pulls from one on a schedule and stores what it returns as rate versions.
+type ExchangeRateProvider interface {
+	Name() string
+	FetchRates(ctx context.Context) ([]Quote, error)
+}
+
+// NewProvider builds the provider named by kind: "file" reads source as a
+// JSON or CSV file path, "http" fetches JSON from source as a URL.
+func NewProvider(kind string, source string) (ExchangeRateProvider, error) {
+
+	if source == "" {
+		return nil, fmt.Errorf("rate provider %q needs a source", kind)
+	}
+
+	switch kind {
+	case "file":
+		return NewFileProvider(source), nil
+	case "http":
+		return NewHTTPProvider(source), nil
+	default:
+		return nil, fmt.Errorf("unknown rate provider %q", kind)
+	}
+}
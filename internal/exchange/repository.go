@@ -2,6 +2,9 @@ package exchange
 
 import (
 	"context"
+	"errors"
+	"time"
+	"wallet-service/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,9 +16,17 @@ type ExchangeRepository interface {
 	CreateExchangeRate(ctx context.Context, exchangeRate *ExchangeRate) error
 	GetAllExchangeRate(ctx context.Context) ([]*ExchangeRate, error)
 	GetExchangeRate(ctx context.Context, id primitive.ObjectID) (*ExchangeRate, error)
-	GetFirstExchangeRate(ctx context.Context) (*ExchangeRate, error)
-	UpdateExchangeRate(ctx context.Context, id primitive.ObjectID, exchangeRate bson.M) error
+	GetEffectiveExchangeRate(ctx context.Context, currency string, quoteCurrency string, at time.Time) (*ExchangeRate, error)
+	GetPreviousVersion(ctx context.Context, currency string, quoteCurrency string, before time.Time) (*ExchangeRate, error)
+	GetNextVersion(ctx context.Context, currency string, quoteCurrency string, after time.Time) (*ExchangeRate, error)
+	GetRateVersions(ctx context.Context, filter bson.M) ([]*ExchangeRate, error)
+	SetEffectiveTo(ctx context.Context, id primitive.ObjectID, effectiveTo *time.Time) error
 	DeleteExchangeRate(ctx context.Context, id primitive.ObjectID) error
+	MigrateMoneyFields(ctx context.Context) error
+	MigrateQuoteCurrency(ctx context.Context, quoteCurrency string) error
+	MigrateEffectiveDates(ctx context.Context) error
+	EnsureIndexes(ctx context.Context) error
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type exchangeRepository struct {
@@ -30,6 +41,9 @@ func NewExchangeRepository(collection *mongo.Collection) ExchangeRepository {
 
 func (r *exchangeRepository) CreateExchangeRate(ctx context.Context, exchangeRate *ExchangeRate) error {
 	_, err := r.collection.InsertOne(ctx, exchangeRate)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrRateVersionExists
+	}
 	return err
 }
 
@@ -56,22 +70,91 @@ func (r *exchangeRepository) GetExchangeRate(ctx context.Context, id primitive.O
 	return &exchangeRate, err
 }
 
-func (r *exchangeRepository) GetFirstExchangeRate(ctx context.Context) (*ExchangeRate, error) {
+// GetEffectiveExchangeRate returns the version of the pair's rate in effect
+// at the given time, or ErrRateNotFound.
+func (r *exchangeRepository) GetEffectiveExchangeRate(ctx context.Context, currency string, quoteCurrency string, at time.Time) (*ExchangeRate, error) {
+
+	filter := bson.M{
+		"currency":       currency,
+		"quote_currency": quoteCurrency,
+		"effective_from": bson.M{"$lte": at},
+		"$or": bson.A{
+			bson.M{"effective_to": nil},
+			bson.M{"effective_to": bson.M{"$gt": at}},
+		},
+	}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "effective_from", Value: -1}})
+
+	return r.findVersion(ctx, filter, opts)
+}
+
+// GetPreviousVersion returns the latest version of the pair starting before
+// the given time, or ErrRateNotFound.
+func (r *exchangeRepository) GetPreviousVersion(ctx context.Context, currency string, quoteCurrency string, before time.Time) (*ExchangeRate, error) {
+
+	filter := bson.M{"currency": currency, "quote_currency": quoteCurrency, "effective_from": bson.M{"$lt": before}}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "effective_from", Value: -1}})
+
+	return r.findVersion(ctx, filter, opts)
+}
+
+// GetNextVersion returns the earliest version of the pair starting after the
+// given time, or ErrRateNotFound.
+func (r *exchangeRepository) GetNextVersion(ctx context.Context, currency string, quoteCurrency string, after time.Time) (*ExchangeRate, error) {
+
+	filter := bson.M{"currency": currency, "quote_currency": quoteCurrency, "effective_from": bson.M{"$gt": after}}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "effective_from", Value: 1}})
+
+	return r.findVersion(ctx, filter, opts)
+}
+
+func (r *exchangeRepository) findVersion(ctx context.Context, filter bson.M, opts *options.FindOneOptions) (*ExchangeRate, error) {
 
 	var exchangeRate ExchangeRate
 
-	otps := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&exchangeRate)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &exchangeRate, nil
+}
+
+// GetRateVersions returns the versions matching filter, newest first.
+func (r *exchangeRepository) GetRateVersions(ctx context.Context, filter bson.M) ([]*ExchangeRate, error) {
 
-	err := r.collection.FindOne(ctx, bson.M{"active": true}, otps).Decode(&exchangeRate)
+	var exchangeRates []*ExchangeRate
+
+	opts := options.Find().SetSort(bson.D{{Key: "effective_from", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &exchangeRate, err
+	if err = cursor.All(ctx, &exchangeRates); err != nil {
+		return nil, err
+	}
+
+	return exchangeRates, nil
 }
 
-func (r *exchangeRepository) UpdateExchangeRate(ctx context.Context, id primitive.ObjectID, exchangeRate bson.M) error {
-	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": exchangeRate})
+// SetEffectiveTo ends a version, or reopens it when effectiveTo is nil. It
+// is the only change ever made to a stored version.
+func (r *exchangeRepository) SetEffectiveTo(ctx context.Context, id primitive.ObjectID, effectiveTo *time.Time) error {
+
+	update := bson.M{"$set": bson.M{"effective_to": effectiveTo, "updated_at": time.Now()}}
+	if effectiveTo == nil {
+		update = bson.M{"$unset": bson.M{"effective_to": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
 	return err
 }
 
@@ -79,3 +162,76 @@ func (r *exchangeRepository) DeleteExchangeRate(ctx context.Context, id primitiv
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+// MigrateMoneyFields rewrites rates stored as floats into the fixed-point
+// int64 form used by money.Rate.
+func (r *exchangeRepository) MigrateMoneyFields(ctx context.Context) error {
+	_, err := r.collection.UpdateMany(ctx, money.LegacyFilter("rate"), money.LegacyUpdate("rate", money.RateScale))
+	return err
+}
+
+// MigrateQuoteCurrency gives rates created before pairs existed the quote
+// currency they were always converting into: the wallet currency.
+func (r *exchangeRepository) MigrateQuoteCurrency(ctx context.Context, quoteCurrency string) error {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"quote_currency": bson.M{"$exists": false}},
+		bson.M{"quote_currency": ""},
+	}}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"quote_currency": quoteCurrency}})
+	return err
+}
+
+// MigrateEffectiveDates turns rates from before versioning into versions:
+// each took effect when it was created, and a deactivated rate stopped when
+// it was last updated.
+func (r *exchangeRepository) MigrateEffectiveDates(ctx context.Context) error {
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"effective_from": "$created_at",
+			"effective_to": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$active", false}},
+				"$updated_at",
+				"$$REMOVE",
+			}},
+		}}},
+		{{Key: "$unset", Value: "active"}},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, bson.M{"effective_from": bson.M{"$exists": false}}, update)
+	return err
+}
+
+// EnsureIndexes allows one version of a pair per start time and serves the
+// effective-rate lookups. It replaces the one-active-rate-per-pair index
+// used before rates were versioned.
+func (r *exchangeRepository) EnsureIndexes(ctx context.Context) error {
+
+	_, err := r.collection.Indexes().DropOne(ctx, "currency_1_quote_currency_1")
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound")) {
+		return err
+	}
+
+	_, err = r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "currency", Value: 1}, {Key: "quote_currency", Value: 1}, {Key: "effective_from", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// WithTransaction runs fn inside a MongoDB multi-document transaction.
+func (r *exchangeRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+
+	session, err := r.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
@@ -1,13 +1,22 @@
 package exchange
 
+import (
+	"time"
+	"wallet-service/pkg/money"
+)
+
+// CreateExchangeRateRequest adds a version of a pair's rate. EffectiveFrom
+// defaults to now and may be in the future to schedule a rate.
 type CreateExchangeRateRequest struct {
-	Currency string  `json:"currency"`
-	Rate     float64 `json:"rate"`
-	Active   bool    `json:"active"`
+	Currency      string     `json:"currency"`
+	QuoteCurrency string     `json:"quote_currency"`
+	Rate          money.Rate `json:"rate"`
+	EffectiveFrom *time.Time `json:"effective_from"`
 }
 
+// UpdateExchangeRateRequest supersedes a rate with a new version of the
+// same pair; the existing version is never changed.
 type UpdateExchangeRateRequest struct {
-	Currency *string  `json:"currency"`
-	Rate     *float64 `json:"rate"`
-	Active   *bool    `json:"active"`
-}
\ No newline at end of file
+	Rate          money.Rate `json:"rate"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+}
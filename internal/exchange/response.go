@@ -1 +1,30 @@
-package exchange
\ No newline at end of file
+package exchange
+
+import (
+	"time"
+	"wallet-service/pkg/money"
+)
+
+// Conversion is an amount converted between currencies together with the
+// rate version used, which is nil when both currencies are the same.
+type Conversion struct {
+	Amount money.Amount
+	Rate   *ExchangeRate
+}
+
+// SyncReport is the outcome of one provider sync.
+type SyncReport struct {
+	Provider  string          `json:"provider"`
+	SyncedAt  time.Time       `json:"synced_at"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"`
+	Rejected  []RejectedQuote `json:"rejected,omitempty"`
+}
+
+// RejectedQuote is a provider quote that was not stored, with the rate left
+// in effect instead.
+type RejectedQuote struct {
+	Quote
+	Current *money.Rate `json:"current,omitempty"`
+	Reason  string      `json:"reason"`
+}
@@ -1,14 +1,19 @@
 package exchange
 
-import "github.com/gin-gonic/gin"
+import (
+    "wallet-service/internal/middleware"
+
+    "github.com/gin-gonic/gin"
+)
 
 func RegisterRoutes(r *gin.Engine, handler *ExchangeHandler) {
     exchangeGroup := r.Group("/api/v1/exchange") 
     {
-        exchangeGroup.POST("", handler.CreateExchangeRate)
+        exchangeGroup.POST("", middleware.Secured(), middleware.Authorize(middleware.PermRateWrite), handler.CreateExchangeRate)
         exchangeGroup.GET("", handler.GetAllExchangeRate)
         exchangeGroup.GET("/:id", handler.GetExchangeRate)
-        exchangeGroup.PUT("/:id", handler.UpdateExchangeRate)
-        exchangeGroup.DELETE("/:id", handler.DeleteExchangeRate)
+        exchangeGroup.GET("/history/:currency", handler.GetRateHistory)
+        exchangeGroup.PUT("/:id", middleware.Secured(), middleware.Authorize(middleware.PermRateWrite), handler.UpdateExchangeRate)
+        exchangeGroup.DELETE("/:id", middleware.Secured(), middleware.Authorize(middleware.PermRateWrite), handler.DeleteExchangeRate)
     }
 }
\ No newline at end of file
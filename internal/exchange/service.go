@@ -4,17 +4,20 @@ import (
 	"context"
 	"fmt"
 	"time"
+	"wallet-service/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ExchangeService interface {
-	CreateExchangeRate(ctx context.Context, req *CreateExchangeRateRequest) error
+	CreateExchangeRate(ctx context.Context, req *CreateExchangeRateRequest) (*ExchangeRate, error)
 	GetAllExchangeRate(ctx context.Context) ([]*ExchangeRate, error)
 	GetExchangeRate(ctx context.Context, id string) (*ExchangeRate, error)
-	GetFirstExchangeRate(ctx context.Context) (*ExchangeRate, error)
-	UpdateExchangeRate(ctx context.Context, id string, req *UpdateExchangeRateRequest) error
+	GetRateHistory(ctx context.Context, currency string, quoteCurrency string) ([]*ExchangeRate, error)
+	GetRate(ctx context.Context, currency string, quoteCurrency string, at time.Time) (*ExchangeRate, error)
+	Convert(ctx context.Context, amount money.Amount, from string, to string, at time.Time) (*Conversion, error)
+	UpdateExchangeRate(ctx context.Context, id string, req *UpdateExchangeRateRequest) (*ExchangeRate, error)
 	DeleteExchangeRate(ctx context.Context, id string) error
 }
 
@@ -28,27 +31,93 @@ func NewExchangeService(exchangeRepo ExchangeRepository) ExchangeService {
 	}
 }
 
-func (s *exchangeService) CreateExchangeRate(ctx context.Context,req *CreateExchangeRateRequest) error {
+func (s *exchangeService) CreateExchangeRate(ctx context.Context,req *CreateExchangeRateRequest) (*ExchangeRate, error) {
 	
 	if req.Currency == "" {
-		return fmt.Errorf("currency is required")
+		return nil, fmt.Errorf("currency is required")
 	}
 
-	if req.Rate == 0 {
-		return fmt.Errorf("rate is required")
+	if req.QuoteCurrency == "" {
+		return nil, fmt.Errorf("quote_currency is required")
 	}
 
+	if req.QuoteCurrency == req.Currency {
+		return nil, fmt.Errorf("currency and quote_currency must differ")
+	}
+
+	return s.addVersion(ctx, req.Currency, req.QuoteCurrency, req.Rate, req.EffectiveFrom)
+
+}
+
+// addVersion inserts a version of the pair's rate taking effect at
+// effectiveFrom, or now. It ends where the next scheduled version begins,
+// and the version it supersedes is ended where it begins. Versions cannot
+// start in the past, so no rate already used for a deposit is ever cut short.
+func (s *exchangeService) addVersion(ctx context.Context, currency string, quoteCurrency string, rate money.Rate, effectiveFrom *time.Time) (*ExchangeRate, error) {
+
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be greater than 0")
+	}
+
+	now := time.Now()
+
+	from := now
+	if effectiveFrom != nil {
+		if effectiveFrom.Before(now) {
+			return nil, fmt.Errorf("effective_from cannot be in the past")
+		}
+		from = *effectiveFrom
+	}
+
+	// Mongo keeps milliseconds; truncating keeps adjacent versions' bounds
+	// exactly equal once stored.
+	from = from.Truncate(time.Millisecond)
+
 	exchangeRate := &ExchangeRate {
 		ID: primitive.NewObjectID(),
-		Currency: req.Currency,
-		Rate: req.Rate,
-		Active: true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Currency: currency,
+		QuoteCurrency: quoteCurrency,
+		Rate: rate,
+		EffectiveFrom: from,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	return s.exchangeRepo.CreateExchangeRate(ctx, exchangeRate)
+	// Concurrent versions of one pair conflict instead of overlapping
+	err := s.exchangeRepo.WithTransaction(ctx, func(ctx context.Context) error {
+
+		exchangeRate.EffectiveTo = nil
+
+		next, err := s.exchangeRepo.GetNextVersion(ctx, currency, quoteCurrency, from)
+		if err != nil && err != ErrRateNotFound {
+			return err
+		}
+		if next != nil {
+			exchangeRate.EffectiveTo = &next.EffectiveFrom
+		}
+
+		previous, err := s.exchangeRepo.GetPreviousVersion(ctx, currency, quoteCurrency, from)
+		if err != nil && err != ErrRateNotFound {
+			return err
+		}
 
+		if err := s.exchangeRepo.CreateExchangeRate(ctx, exchangeRate); err != nil {
+			return err
+		}
+
+		if previous != nil && (previous.EffectiveTo == nil || previous.EffectiveTo.After(from)) {
+			if err := s.exchangeRepo.SetEffectiveTo(ctx, previous.ID, &from); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return exchangeRate, nil
 }
 
 func (s *exchangeService) GetAllExchangeRate(ctx context.Context) ([]*ExchangeRate, error) {
@@ -70,56 +139,128 @@ func (s *exchangeService) GetExchangeRate(ctx context.Context, id string) (*Exch
 
 }
 
-func (s *exchangeService) GetFirstExchangeRate(ctx context.Context) (*ExchangeRate, error) {
-	return s.exchangeRepo.GetFirstExchangeRate(ctx)
-}
+// GetRateHistory returns every version of the pairs involving currency,
+// newest first, narrowed to the pair with quoteCurrency when it is given.
+func (s *exchangeService) GetRateHistory(ctx context.Context, currency string, quoteCurrency string) ([]*ExchangeRate, error) {
 
-func (s *exchangeService) UpdateExchangeRate(ctx context.Context, id string, req *UpdateExchangeRateRequest) error {
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
 
-	if id == "" {
-		return fmt.Errorf("id is required")
+	filter := bson.M{"$or": bson.A{
+		bson.M{"currency": currency},
+		bson.M{"quote_currency": currency},
+	}}
+
+	if quoteCurrency != "" {
+		filter = bson.M{"$or": bson.A{
+			bson.M{"currency": currency, "quote_currency": quoteCurrency},
+			bson.M{"currency": quoteCurrency, "quote_currency": currency},
+		}}
 	}
 
-	objectID, err := primitive.ObjectIDFromHex(id)
+	return s.exchangeRepo.GetRateVersions(ctx, filter)
+}
+
+func (s *exchangeService) GetRate(ctx context.Context, currency string, quoteCurrency string, at time.Time) (*ExchangeRate, error) {
+
+	exchangeRate, err := s.exchangeRepo.GetEffectiveExchangeRate(ctx, currency, quoteCurrency, at)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%s to %s: %w", currency, quoteCurrency, err)
 	}
 
-	updateExchangeRate := bson.M{}
+	return exchangeRate, nil
+}
+
+// Convert expresses amount, given in from, in the to currency with the rate
+// version in effect at the given time. A rate for the pair is applied
+// directly; failing that, a rate for the reverse pair is inverted.
+// Same-currency amounts are returned unchanged.
+func (s *exchangeService) Convert(ctx context.Context, amount money.Amount, from string, to string, at time.Time) (*Conversion, error) {
 
-	if req.Currency != nil {
-		updateExchangeRate["currency"] = req.Currency
+	if from == to {
+		return &Conversion{Amount: amount}, nil
 	}
 
-	if req.Rate != nil {
-		updateExchangeRate["rate"] = req.Rate
+	exchangeRate, err := s.exchangeRepo.GetEffectiveExchangeRate(ctx, from, to, at)
+	if err == nil {
+		converted, err := exchangeRate.Rate.Convert(amount)
+		if err != nil {
+			return nil, err
+		}
+		return &Conversion{Amount: converted, Rate: exchangeRate}, nil
+	}
+	if err != ErrRateNotFound {
+		return nil, err
+	}
+
+	exchangeRate, err = s.exchangeRepo.GetEffectiveExchangeRate(ctx, to, from, at)
+	if err == ErrRateNotFound {
+		return nil, fmt.Errorf("%s to %s: %w", from, to, err)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	if req.Active != nil {
-		updateExchangeRate["active"] = req.Active
+	converted, err := exchangeRate.Rate.Invert(amount)
+	if err != nil {
+		return nil, err
 	}
 
-	updateExchangeRate["updated_at"] = time.Now()
+	return &Conversion{Amount: converted, Rate: exchangeRate}, nil
+}
+
+// UpdateExchangeRate supersedes the version's pair with a new rate.
+func (s *exchangeService) UpdateExchangeRate(ctx context.Context, id string, req *UpdateExchangeRateRequest) (*ExchangeRate, error) {
 
-	if len(updateExchangeRate) == 1 {
-		return fmt.Errorf("no fields to update")
+	exchangeRate, err := s.GetExchangeRate(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.exchangeRepo.UpdateExchangeRate(ctx, objectID, updateExchangeRate)
+	return s.addVersion(ctx, exchangeRate.Currency, exchangeRate.QuoteCurrency, req.Rate, req.EffectiveFrom)
 	
 }
 
+// DeleteExchangeRate cancels a scheduled version, closing the gap it leaves,
+// or ends the version currently in effect. Past versions are kept as the
+// record of what deposits were converted with.
 func (s *exchangeService) DeleteExchangeRate(ctx context.Context, id string) error {
 
-	if id == "" {
-		return fmt.Errorf("id is required")
-	}
+	// The version is removed and its predecessor re-linked together
+	return s.exchangeRepo.WithTransaction(ctx, func(ctx context.Context) error {
 
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
+		exchangeRate, err := s.GetExchangeRate(ctx, id)
+		if err != nil {
+			return err
+		}
 
-	return s.exchangeRepo.DeleteExchangeRate(ctx, objectID)
-	
-}
\ No newline at end of file
+		now := time.Now()
+
+		if exchangeRate.EffectiveTo != nil && !exchangeRate.EffectiveTo.After(now) {
+			return fmt.Errorf("rate version has already ended")
+		}
+
+		if !exchangeRate.EffectiveFrom.After(now) {
+			return s.exchangeRepo.SetEffectiveTo(ctx, exchangeRate.ID, &now)
+		}
+
+		if err := s.exchangeRepo.DeleteExchangeRate(ctx, exchangeRate.ID); err != nil {
+			return err
+		}
+
+		previous, err := s.exchangeRepo.GetPreviousVersion(ctx, exchangeRate.Currency, exchangeRate.QuoteCurrency, exchangeRate.EffectiveFrom)
+		if err == ErrRateNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if previous.EffectiveTo != nil && previous.EffectiveTo.Equal(exchangeRate.EffectiveFrom) {
+			return s.exchangeRepo.SetEffectiveTo(ctx, previous.ID, exchangeRate.EffectiveTo)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RateSync copies a provider's rates into the service as new rate versions.
+// A quote that moves further from the rate in effect than MaxChangePercent
+// is rejected and the last good rate stays in effect until someone looks.
+type RateSync struct {
+	exchangeService  ExchangeService
+	provider         ExchangeRateProvider
+	maxChangePercent float64
+}
+
+func NewRateSync(exchangeService ExchangeService, provider ExchangeRateProvider, maxChangePercent float64) *RateSync {
+	return &RateSync{
+		exchangeService:  exchangeService,
+		provider:         provider,
+		maxChangePercent: maxChangePercent,
+	}
+}
+
+// Sync fetches the provider's quotes once. A failure on one pair does not
+// stop the others; it is listed in the report.
+func (s *RateSync) Sync(ctx context.Context) (*SyncReport, error) {
+
+	quotes, err := s.provider.FetchRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rates from %s: %w", s.provider.Name(), err)
+	}
+
+	report := &SyncReport{Provider: s.provider.Name(), SyncedAt: time.Now()}
+
+	for _, quote := range quotes {
+		if quote.Rate <= 0 {
+			report.Rejected = append(report.Rejected, RejectedQuote{Quote: quote, Reason: "rate must be greater than 0"})
+			continue
+		}
+
+		current, err := s.exchangeService.GetRate(ctx, quote.Currency, quote.QuoteCurrency, report.SyncedAt)
+		if err != nil && !errors.Is(err, ErrRateNotFound) {
+			report.Rejected = append(report.Rejected, RejectedQuote{Quote: quote, Reason: err.Error()})
+			continue
+		}
+
+		if current != nil && current.Rate == quote.Rate {
+			report.Unchanged++
+			continue
+		}
+
+		if current != nil && s.exceedsMaxChange(current, quote) {
+			report.Rejected = append(report.Rejected, RejectedQuote{
+				Quote:   quote,
+				Current: &current.Rate,
+				Reason:  fmt.Sprintf("moves more than %g%% from %s", s.maxChangePercent, current.Rate),
+			})
+			continue
+		}
+
+		_, err = s.exchangeService.CreateExchangeRate(ctx, &CreateExchangeRateRequest{
+			Currency:      quote.Currency,
+			QuoteCurrency: quote.QuoteCurrency,
+			Rate:          quote.Rate,
+		})
+		if err != nil {
+			report.Rejected = append(report.Rejected, RejectedQuote{Quote: quote, Reason: err.Error()})
+			continue
+		}
+
+		report.Updated++
+	}
+
+	return report, nil
+}
+
+// exceedsMaxChange reports whether quote is further from the current rate
+// than the configured percentage. Zero disables the check.
+func (s *RateSync) exceedsMaxChange(current *ExchangeRate, quote Quote) bool {
+
+	if s.maxChangePercent <= 0 {
+		return false
+	}
+
+	change := quote.Rate - current.Rate
+	if change < 0 {
+		change = -change
+	}
+
+	return float64(change)*100 > s.maxChangePercent*float64(current.Rate)
+}
@@ -0,0 +1,160 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"wallet-service/pkg/money"
+)
+
+// staticProvider returns its quotes, or err.
+type staticProvider struct {
+	quotes []Quote
+	err    error
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) FetchRates(ctx context.Context) ([]Quote, error) {
+	return p.quotes, p.err
+}
+
+// fakeRates is an ExchangeService holding the rate in effect per pair.
+type fakeRates struct {
+	ExchangeService
+	rates map[string]money.Rate
+}
+
+func pair(currency string, quoteCurrency string) string {
+	return currency + "/" + quoteCurrency
+}
+
+func (f *fakeRates) GetRate(ctx context.Context, currency string, quoteCurrency string, at time.Time) (*ExchangeRate, error) {
+	rate, ok := f.rates[pair(currency, quoteCurrency)]
+	if !ok {
+		return nil, ErrRateNotFound
+	}
+	return &ExchangeRate{Currency: currency, QuoteCurrency: quoteCurrency, Rate: rate}, nil
+}
+
+func (f *fakeRates) CreateExchangeRate(ctx context.Context, req *CreateExchangeRateRequest) (*ExchangeRate, error) {
+	if req.Currency == "" || req.QuoteCurrency == "" {
+		return nil, fmt.Errorf("currency and quote_currency are required")
+	}
+	f.rates[pair(req.Currency, req.QuoteCurrency)] = req.Rate
+	return &ExchangeRate{Currency: req.Currency, QuoteCurrency: req.QuoteCurrency, Rate: req.Rate}, nil
+}
+
+func TestExceedsMaxChange(t *testing.T) {
+	current := &ExchangeRate{Rate: 1000000}
+
+	tests := []struct {
+		name      string
+		maxChange float64
+		rate      money.Rate
+		want      bool
+	}{
+		{name: "unchanged", maxChange: 10, rate: 1000000, want: false},
+		{name: "rise at the limit", maxChange: 10, rate: 1100000, want: false},
+		{name: "rise just over the limit", maxChange: 10, rate: 1100001, want: true},
+		{name: "fall at the limit", maxChange: 10, rate: 900000, want: false},
+		{name: "fall just over the limit", maxChange: 10, rate: 899999, want: true},
+		{name: "fractional limit", maxChange: 0.5, rate: 1005000, want: false},
+		{name: "just over fractional limit", maxChange: 0.5, rate: 1005001, want: true},
+		{name: "zero disables the check", maxChange: 0, rate: 100000000, want: false},
+		{name: "negative disables the check", maxChange: -1, rate: 1, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRateSync(nil, nil, tt.maxChange)
+			if got := s.exceedsMaxChange(current, Quote{Rate: tt.rate}); got != tt.want {
+				t.Errorf("exceedsMaxChange(%s -> %s, %g%%) = %v, want %v", current.Rate, tt.rate, tt.maxChange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncRejectsLargeMovesAndKeepsTheCurrentRate(t *testing.T) {
+	rates := &fakeRates{rates: map[string]money.Rate{
+		pair("USD", "POINT"): 1000000,
+		pair("VND", "POINT"): 1000,
+		pair("EUR", "POINT"): 2000000,
+	}}
+	provider := &staticProvider{quotes: []Quote{
+		{Currency: "USD", QuoteCurrency: "POINT", Rate: 1100000}, // at the limit
+		{Currency: "VND", QuoteCurrency: "POINT", Rate: 2000},    // doubled
+		{Currency: "EUR", QuoteCurrency: "POINT", Rate: 2000000}, // unchanged
+		{Currency: "XYZ", QuoteCurrency: "POINT", Rate: 5000000}, // no rate yet
+		{Currency: "", QuoteCurrency: "POINT", Rate: 1000000},    // refused by the service
+		{Currency: "GBP", QuoteCurrency: "POINT", Rate: 0},
+	}}
+
+	report, err := NewRateSync(rates, provider, 10).Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if report.Updated != 2 || report.Unchanged != 1 || len(report.Rejected) != 3 {
+		t.Errorf("report = %d updated, %d unchanged, %d rejected; want 2, 1, 3", report.Updated, report.Unchanged, len(report.Rejected))
+	}
+
+	for _, rejected := range report.Rejected {
+		if rejected.Currency == "VND" && (rejected.Current == nil || *rejected.Current != 1000) {
+			t.Errorf("VND rejection reports current rate %v, want 0.001", rejected.Current)
+		}
+	}
+
+	want := map[string]money.Rate{
+		pair("USD", "POINT"): 1100000,
+		pair("VND", "POINT"): 1000,
+		pair("EUR", "POINT"): 2000000,
+		pair("XYZ", "POINT"): 5000000,
+	}
+	for key, rate := range want {
+		if got := rates.rates[key]; got != rate {
+			t.Errorf("%s rate = %s, want %s", key, got, rate)
+		}
+	}
+	if _, ok := rates.rates[pair("GBP", "POINT")]; ok {
+		t.Error("a zero GBP rate was stored")
+	}
+}
+
+func TestSyncWithoutMaxChangeAcceptsAnyMove(t *testing.T) {
+	rates := &fakeRates{rates: map[string]money.Rate{pair("VND", "POINT"): 1000}}
+	provider := &staticProvider{quotes: []Quote{{Currency: "VND", QuoteCurrency: "POINT", Rate: 1000000}}}
+
+	report, err := NewRateSync(rates, provider, 0).Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if report.Updated != 1 {
+		t.Errorf("updated = %d, want 1", report.Updated)
+	}
+	if got := rates.rates[pair("VND", "POINT")]; got != 1000000 {
+		t.Errorf("VND rate = %s, want 1", got)
+	}
+}
+
+func TestSyncKeepsRatesWhenProviderFails(t *testing.T) {
+	rates := &fakeRates{rates: map[string]money.Rate{pair("VND", "POINT"): 1000}}
+	failure := errors.New("provider unavailable")
+	provider := &staticProvider{
+		quotes: []Quote{{Currency: "VND", QuoteCurrency: "POINT", Rate: 1050}},
+		err:    failure,
+	}
+
+	report, err := NewRateSync(rates, provider, 10).Sync(context.Background())
+	if !errors.Is(err, failure) {
+		t.Fatalf("Sync error = %v, want the provider's error", err)
+	}
+	if report != nil {
+		t.Errorf("report = %+v, want none", report)
+	}
+	if got := rates.rates[pair("VND", "POINT")]; got != 1000 {
+		t.Errorf("VND rate = %s, want the last good rate 0.001", got)
+	}
+}
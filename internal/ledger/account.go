@@ -0,0 +1,45 @@
+package ledger
+
+import (
+	"fmt"
+	"regexp"
+	"wallet-service/pkg/money"
+)
+
+// Accounts are plain strings so they can be grouped and queried directly.
+// User wallets are liabilities of the platform: a credit increases what the
+// user holds and a debit spends it.
+const (
+	// RevenueAccount receives everything users spend on purchases.
+	RevenueAccount = "platform:revenue"
+	// OpeningBalanceAccount funds the balances wallets already held when
+	// the ledger was introduced.
+	OpeningBalanceAccount = "platform:opening_balance"
+)
+
+// WalletAccount is the account backing one of a user's wallets. Its credit
+// balance is what Wallet.Balance projects.
+func WalletAccount(userID string, walletType string) string {
+	return fmt.Sprintf("wallet:%s:%s", userID, walletType)
+}
+
+// walletAccountPattern matches the wallet accounts of every user for one
+// wallet type.
+func walletAccountPattern(walletType string) string {
+	return "^wallet:.*:" + regexp.QuoteMeta(walletType) + "$"
+}
+
+// FundingAccount is the source an admin top-up is drawn from, so every
+// credit to a wallet can be traced back to the admin who funded it.
+func FundingAccount(adminID string) string {
+	return fmt.Sprintf("funding:admin:%s", adminID)
+}
+
+// Transfer builds the two postings that move amount from one account to
+// another: a debit on from and a credit on to.
+func Transfer(from string, to string, amount money.Amount) []Posting {
+	return []Posting{
+		{Account: from, Debit: amount},
+		{Account: to, Credit: amount},
+	}
+}
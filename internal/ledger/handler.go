@@ -0,0 +1,45 @@
+package ledger
+
+import (
+	"net/http"
+	"wallet-service/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	ledgerService LedgerService
+}
+
+func NewLedgerHandler(ledgerService LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerService: ledgerService,
+	}
+}
+
+func (h *LedgerHandler) GetTrialBalance(c *gin.Context) {
+
+	trial, err := h.ledgerService.GetTrialBalance(c)
+	if err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", trial)
+
+}
+
+func (h *LedgerHandler) GetAccountBalance(c *gin.Context) {
+
+	account := c.Param("account")
+	currency := c.Query("currency")
+
+	balance, err := h.ledgerService.GetAccountBalance(c, account, currency)
+	if err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", balance)
+
+}
@@ -0,0 +1,31 @@
+package ledger
+
+import (
+	"time"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JournalEntry is one balanced double-entry record: the debits of its
+// postings always equal the credits. OperationID links the entry to the
+// wallet transactions written by the same operation.
+//
+// Every posting of an entry is in Currency; amounts in different currencies
+// never balance each other.
+type JournalEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OperationID primitive.ObjectID `bson:"operation_id" json:"operation_id"`
+	Type        string             `bson:"type" json:"type"`
+	Currency    string             `bson:"currency" json:"currency"`
+	Postings    []Posting          `bson:"postings" json:"postings"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Posting moves value on one account. Exactly one of Debit and Credit is
+// non-zero.
+type Posting struct {
+	Account string       `bson:"account" json:"account"`
+	Debit   money.Amount `bson:"debit" json:"debit"`
+	Credit  money.Amount `bson:"credit" json:"credit"`
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"context"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type LedgerRepository interface {
+	CreateJournalEntry(ctx context.Context, entry *JournalEntry) error
+	GetAccountBalances(ctx context.Context, filter bson.M) ([]*AccountBalance, error)
+	SetMissingCurrency(ctx context.Context, accountPattern string, currency string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type ledgerRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLedgerRepository(collection *mongo.Collection) LedgerRepository {
+	return &ledgerRepository{
+		collection: collection,
+	}
+}
+
+func (r *ledgerRepository) CreateJournalEntry(ctx context.Context, entry *JournalEntry) error {
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// GetAccountBalances sums debits and credits per account and currency over
+// the postings matching filter. The filter is applied to entries first, so
+// the postings.account index is used, then again to their postings.
+func (r *ledgerRepository) GetAccountBalances(ctx context.Context, filter bson.M) ([]*AccountBalance, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$unwind", Value: "$postings"}},
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"currency": "$currency", "account": "$postings.account"},
+			"debit":  bson.M{"$sum": "$postings.debit"},
+			"credit": bson.M{"$sum": "$postings.credit"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.currency", Value: 1}, {Key: "_id.account", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Key struct {
+			Currency string `bson:"currency"`
+			Account  string `bson:"account"`
+		} `bson:"_id"`
+		Debit  money.Amount `bson:"debit"`
+		Credit money.Amount `bson:"credit"`
+	}
+
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	balances := make([]*AccountBalance, 0, len(rows))
+	for _, row := range rows {
+		balances = append(balances, &AccountBalance{
+			Account:  row.Key.Account,
+			Currency: row.Key.Currency,
+			Debit:    row.Debit,
+			Credit:   row.Credit,
+			Balance:  row.Credit - row.Debit,
+		})
+	}
+
+	return balances, nil
+}
+
+// SetMissingCurrency sets currency on the entries written before entries had
+// one that post to an account matching accountPattern.
+func (r *ledgerRepository) SetMissingCurrency(ctx context.Context, accountPattern string, currency string) error {
+	filter := bson.M{
+		"currency":         bson.M{"$exists": false},
+		"postings.account": bson.M{"$regex": accountPattern},
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"currency": currency}})
+	return err
+}
+
+func (r *ledgerRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "operation_id", Value: 1}}},
+		{Keys: bson.D{{Key: "postings.account", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,27 @@
+package ledger
+
+import "wallet-service/pkg/money"
+
+type AccountBalance struct {
+	Account  string       `json:"account"`
+	Currency string       `json:"currency"`
+	Debit    money.Amount `json:"debit"`
+	Credit   money.Amount `json:"credit"`
+	// Balance is Credit - Debit, the natural sign for wallet accounts.
+	Balance money.Amount `json:"balance"`
+}
+
+// TrialBalance totals the journal per currency; it is balanced when every
+// currency is.
+type TrialBalance struct {
+	Currencies []*CurrencyTrialBalance `json:"currencies"`
+	Balanced   bool                    `json:"balanced"`
+}
+
+type CurrencyTrialBalance struct {
+	Currency    string            `json:"currency"`
+	Accounts    []*AccountBalance `json:"accounts"`
+	TotalDebit  money.Amount      `json:"total_debit"`
+	TotalCredit money.Amount      `json:"total_credit"`
+	Balanced    bool              `json:"balanced"`
+}
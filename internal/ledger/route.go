@@ -0,0 +1,15 @@
+package ledger
+
+import (
+	"wallet-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(r *gin.Engine, handler *LedgerHandler) {
+	ledgerGroup := r.Group("/api/v1/ledger")
+	{
+		ledgerGroup.GET("/trial_balance", middleware.Secured(), middleware.Authorize(middleware.PermLedgerRead), handler.GetTrialBalance)
+		ledgerGroup.GET("/accounts/:account", middleware.Secured(), middleware.Authorize(middleware.PermLedgerRead), handler.GetAccountBalance)
+	}
+}
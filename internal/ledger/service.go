@@ -0,0 +1,142 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LedgerService interface {
+	Post(ctx context.Context, operationID primitive.ObjectID, entryType string, currency string, postings []Posting) (*JournalEntry, error)
+	GetTrialBalance(ctx context.Context) (*TrialBalance, error)
+	GetAccountBalance(ctx context.Context, account string, currency string) (*AccountBalance, error)
+	MigrateWalletCurrency(ctx context.Context, walletType string, currency string) error
+}
+
+type ledgerService struct {
+	ledgerRepo LedgerRepository
+}
+
+func NewLedgerService(ledgerRepo LedgerRepository) LedgerService {
+	return &ledgerService{
+		ledgerRepo: ledgerRepo,
+	}
+}
+
+// Post records a journal entry after checking that it balances. Call it with
+// the same context as the wallet updates it describes so both commit in the
+// same Mongo transaction.
+func (s *ledgerService) Post(ctx context.Context, operationID primitive.ObjectID, entryType string, currency string, postings []Posting) (*JournalEntry, error) {
+
+	if entryType == "" {
+		return nil, fmt.Errorf("entry type is required")
+	}
+
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	if len(postings) < 2 {
+		return nil, fmt.Errorf("a journal entry needs at least two postings")
+	}
+
+	var debit, credit money.Amount
+	for _, posting := range postings {
+		if posting.Account == "" {
+			return nil, fmt.Errorf("posting account is required")
+		}
+		if posting.Debit < 0 || posting.Credit < 0 {
+			return nil, fmt.Errorf("posting amounts cannot be negative")
+		}
+		if (posting.Debit == 0) == (posting.Credit == 0) {
+			return nil, fmt.Errorf("posting on %s must have exactly one of debit or credit", posting.Account)
+		}
+		debit += posting.Debit
+		credit += posting.Credit
+	}
+
+	if debit != credit {
+		return nil, fmt.Errorf("unbalanced journal entry: debit %s, credit %s", debit, credit)
+	}
+
+	entry := &JournalEntry{
+		ID:          primitive.NewObjectID(),
+		OperationID: operationID,
+		Type:        entryType,
+		Currency:    currency,
+		Postings:    postings,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.ledgerRepo.CreateJournalEntry(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (s *ledgerService) GetTrialBalance(ctx context.Context) (*TrialBalance, error) {
+
+	accounts, err := s.ledgerRepo.GetAccountBalances(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %w", err)
+	}
+
+	// Accounts come sorted by currency
+	trial := &TrialBalance{Currencies: []*CurrencyTrialBalance{}, Balanced: true}
+	var current *CurrencyTrialBalance
+	for _, account := range accounts {
+		if current == nil || current.Currency != account.Currency {
+			current = &CurrencyTrialBalance{Currency: account.Currency}
+			trial.Currencies = append(trial.Currencies, current)
+		}
+		current.Accounts = append(current.Accounts, account)
+		current.TotalDebit += account.Debit
+		current.TotalCredit += account.Credit
+	}
+
+	for _, currency := range trial.Currencies {
+		currency.Balanced = currency.TotalDebit == currency.TotalCredit
+		trial.Balanced = trial.Balanced && currency.Balanced
+	}
+
+	return trial, nil
+}
+
+func (s *ledgerService) GetAccountBalance(ctx context.Context, account string, currency string) (*AccountBalance, error) {
+
+	if account == "" {
+		return nil, fmt.Errorf("account is required")
+	}
+
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	balances, err := s.ledgerRepo.GetAccountBalances(ctx, bson.M{"postings.account": account, "currency": currency})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balance: %w", err)
+	}
+
+	if len(balances) == 0 {
+		return &AccountBalance{Account: account, Currency: currency}, nil
+	}
+
+	return balances[0], nil
+}
+
+// MigrateWalletCurrency gives entries written before entries had a currency
+// the currency of the wallet type whose accounts they post to. Every such
+// entry moves at least one wallet.
+func (s *ledgerService) MigrateWalletCurrency(ctx context.Context, walletType string, currency string) error {
+
+	if err := s.ledgerRepo.SetMissingCurrency(ctx, walletAccountPattern(walletType), currency); err != nil {
+		return fmt.Errorf("failed to set currency of %s entries: %w", walletType, err)
+	}
+
+	return nil
+}
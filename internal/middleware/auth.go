@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthConfig chooses the keys tokens are verified with and the claims they
+// must carry. At least one of HMACSecret, PublicKeyFile and JWKSSource is
+// required; Issuer and Audience are only checked when set.
+type AuthConfig struct {
+	HMACSecret string
+	// PublicKeyFile holds one or more PEM encoded RSA or ECDSA public keys
+	// or certificates.
+	PublicKeyFile string
+	// JWKSSource is a JWKS document's URL or local path.
+	JWKSSource string
+	Issuer     string
+	Audience   string
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+var (
+	errUnsupportedAlgorithm = errors.New("token signing algorithm is not accepted")
+	errNoMatchingKey        = errors.New("no key to verify the token with")
+)
+
+// verifier is set by Configure; until then Secured rejects every token.
+var verifier *tokenVerifier
+
+type tokenVerifier struct {
+	hmacSecret []byte
+	publicKeys []crypto.PublicKey
+	jwks       *jwks
+	parser     *jwt.Parser
+}
+
+// Configure loads the verification keys Secured checks tokens against.
+func Configure(cfg AuthConfig) error {
+
+	v, err := newTokenVerifier(cfg)
+	if err != nil {
+		return err
+	}
+
+	verifier = v
+	return nil
+}
+
+func newTokenVerifier(cfg AuthConfig) (*tokenVerifier, error) {
+
+	v := &tokenVerifier{}
+	var methods []string
+
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+
+	if cfg.PublicKeyFile != "" {
+		keys, err := loadPublicKeys(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load public keys: %w", err)
+		}
+		v.publicKeys = keys
+	}
+
+	if cfg.JWKSSource != "" {
+		v.jwks = newJWKS(cfg.JWKSSource)
+		if err := v.jwks.refresh(); err != nil {
+			return nil, fmt.Errorf("load jwks: %w", err)
+		}
+	}
+
+	if v.publicKeys != nil || v.jwks != nil {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no token verification key is configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+func (v *tokenVerifier) verify(tokenString string) (jwt.MapClaims, error) {
+
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(tokenString, claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// keyFunc only ever hands the HMAC secret to HMAC methods and public keys of
+// the matching type to asymmetric ones, so a token cannot pick its own key.
+func (v *tokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+
+	var matches func(crypto.PublicKey) bool
+
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.hmacSecret == nil {
+			return nil, errUnsupportedAlgorithm
+		}
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		matches = func(key crypto.PublicKey) bool {
+			_, ok := key.(*rsa.PublicKey)
+			return ok
+		}
+	case *jwt.SigningMethodECDSA:
+		matches = func(key crypto.PublicKey) bool {
+			_, ok := key.(*ecdsa.PublicKey)
+			return ok
+		}
+	default:
+		return nil, errUnsupportedAlgorithm
+	}
+
+	// JWKS keys matching the kid first, then the PEM keys, which have no IDs
+	kid, _ := token.Header["kid"].(string)
+
+	var candidates []crypto.PublicKey
+	if v.jwks != nil {
+		candidates = append(candidates, v.jwks.keys(kid)...)
+	}
+	candidates = append(candidates, v.publicKeys...)
+
+	var set jwt.VerificationKeySet
+	for _, key := range candidates {
+		if matches(key) {
+			set.Keys = append(set.Keys, key)
+		}
+	}
+
+	if len(set.Keys) == 0 {
+		return nil, errNoMatchingKey
+	}
+
+	return set, nil
+}
+
+// loadPublicKeys reads every RSA or ECDSA public key or certificate in a PEM
+// file.
+func loadPublicKeys(path string) ([]crypto.PublicKey, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s block: %w", strings.ToLower(block.Type), err)
+		}
+
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no public key found")
+	}
+
+	return keys, nil
+}
+
+// tokenError describes why a token was rejected without echoing the
+// parser's internals.
+func tokenError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return errors.New("token is malformed")
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.New("token has expired")
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return errors.New("token is not valid yet")
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return errors.New("token issuer is not accepted")
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return errors.New("token audience is not accepted")
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return errors.New("token is missing a required claim")
+	case errors.Is(err, errUnsupportedAlgorithm), errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return errors.New("token signature is invalid")
+	default:
+		return errors.New("token is invalid")
+	}
+}
@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret   = "test-secret"
+	testIssuer   = "https://auth.example.com"
+	testAudience = "wallet-service"
+)
+
+// testKeys are generated once per run; nothing is read from the repository.
+type testKeys struct {
+	rsa      *rsa.PrivateKey
+	rsaJWKS  *rsa.PrivateKey
+	ec       *ecdsa.PrivateKey
+	other    *rsa.PrivateKey
+	pemFile  string
+	pemBytes []byte
+	jwksFile string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	keys := &testKeys{
+		rsa:     mustRSAKey(t),
+		rsaJWKS: mustRSAKey(t),
+		other:   mustRSAKey(t),
+	}
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	keys.ec = ec
+
+	dir := t.TempDir()
+
+	// The PEM file holds the RSA and EC keys, without key IDs
+	for _, public := range []interface{}{&keys.rsa.PublicKey, &keys.ec.PublicKey} {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		keys.pemBytes = append(keys.pemBytes, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	keys.pemFile = filepath.Join(dir, "keys.pem")
+	if err := os.WriteFile(keys.pemFile, keys.pemBytes, 0o600); err != nil {
+		t.Fatalf("write pem: %v", err)
+	}
+
+	// The JWKS holds a second RSA key under kid "jwks-1"
+	doc, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "jwks-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(keys.rsaJWKS.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(keys.rsaJWKS.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	keys.jwksFile = filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(keys.jwksFile, doc, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	return keys
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	return key
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub": "u1",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func withClaims(overrides jwt.MapClaims) jwt.MapClaims {
+	claims := validClaims()
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestTokenVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+
+	all := AuthConfig{
+		HMACSecret:    testSecret,
+		PublicKeyFile: keys.pemFile,
+		JWKSSource:    keys.jwksFile,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+	}
+	publicOnly := AuthConfig{
+		PublicKeyFile: keys.pemFile,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name: "hmac good signature",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims())
+			},
+		},
+		{
+			name: "hmac wrong secret",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "rsa pem key without kid",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", validClaims())
+			},
+		},
+		{
+			name: "rsa pem key with kid unknown to the jwks",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "pem-key", validClaims())
+			},
+		},
+		{
+			name: "rsa jwks key with its kid",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsaJWKS, "jwks-1", validClaims())
+			},
+		},
+		{
+			name: "rsa jwks key without kid",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsaJWKS, "", validClaims())
+			},
+		},
+		{
+			name: "rsa-pss pem key",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodPS256, keys.rsa, "", validClaims())
+			},
+		},
+		{
+			name: "ecdsa pem key",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodES256, keys.ec, "", validClaims())
+			},
+		},
+		{
+			name: "rsa wrong key",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.other, "", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "rsa wrong key claiming a jwks kid",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.other, "jwks-1", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "alg none",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "hmac signed with the public key when no secret is configured",
+			cfg:  publicOnly,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, keys.pemBytes, "", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "hmac signed with the public key alongside a secret",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, keys.pemBytes, "", validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "expired",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", withClaims(jwt.MapClaims{"exp": past.Unix()}))
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name: "missing exp",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", withClaims(jwt.MapClaims{"exp": nil}))
+			},
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "not valid before nbf",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", withClaims(jwt.MapClaims{"nbf": future.Unix()}))
+			},
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name: "wrong issuer",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", withClaims(jwt.MapClaims{"iss": "https://evil.example.com"}))
+			},
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			cfg:  all,
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, keys.rsa, "", withClaims(jwt.MapClaims{"aud": "other-service"}))
+			},
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newTokenVerifier(tt.cfg)
+			if err != nil {
+				t.Fatalf("newTokenVerifier: %v", err)
+			}
+
+			claims, err := v.verify(tt.token(t))
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if claims["sub"] != "u1" {
+					t.Errorf("sub = %v, want u1", claims["sub"])
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("verify accepted the token, want an error")
+			}
+			// tokenError is what callers see, so compare at that level
+			if got, want := tokenError(err).Error(), tokenError(tt.wantErr).Error(); got != want {
+				t.Errorf("verify error = %v (%q), want %q", err, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet-service/pkg/constants"
+	"wallet-service/pkg/zap"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordingLogger keeps the audit lines written through Infof and Warnf.
+type recordingLogger struct {
+	zap.Logger
+	granted []string
+	denied  []string
+}
+
+func (l *recordingLogger) Infof(template string, args ...interface{}) {
+	l.granted = append(l.granted, fmt.Sprintf(template, args...))
+}
+
+func (l *recordingLogger) Warnf(template string, args ...interface{}) {
+	l.denied = append(l.denied, fmt.Sprintf(template, args...))
+}
+
+// caller is who Secured would have authenticated.
+type caller struct {
+	userID  string
+	roles   []string
+	service string
+}
+
+func (c caller) set(ctx *gin.Context) {
+	ctx.Set(constants.UserID, c.userID)
+	if c.roles != nil {
+		ctx.Set(constants.Roles, c.roles)
+	}
+	if c.service != "" {
+		ctx.Set(constants.Service, c.service)
+	}
+}
+
+// setAuthorizeGlobals swaps the audit logger and role resolver for the test.
+func setAuthorizeGlobals(t *testing.T, resolver RoleResolver) *recordingLogger {
+	t.Helper()
+
+	logger := &recordingLogger{}
+	previousLogger, previousResolver := auditLogger, roleResolver
+	SetAuditLogger(logger)
+	SetRoleResolver(resolver)
+	t.Cleanup(func() {
+		auditLogger, roleResolver = previousLogger, previousResolver
+	})
+
+	return logger
+}
+
+func serve(router *gin.Engine, method string, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+type authorizeCase struct {
+	name        string
+	caller      caller
+	resolver    RoleResolver
+	wantStatus  int
+	wantGranted bool
+	wantDenied  bool
+}
+
+func resolveRoles(roles ...string) RoleResolver {
+	return func(ctx context.Context, userID string) ([]string, error) {
+		return roles, nil
+	}
+}
+
+func failResolve(ctx context.Context, userID string) ([]string, error) {
+	return nil, errors.New("main service unavailable")
+}
+
+func runAuthorizeCases(t *testing.T, tests []authorizeCase, route func(r *gin.Engine, secured gin.HandlerFunc), method string, path string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := setAuthorizeGlobals(t, tt.resolver)
+
+			router := gin.New()
+			route(router, tt.caller.set)
+
+			w := serve(router, method, path)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := len(logger.granted) > 0; got != tt.wantGranted {
+				t.Errorf("granted audit entries = %v, want any: %v", logger.granted, tt.wantGranted)
+			}
+			if got := len(logger.denied) > 0; got != tt.wantDenied {
+				t.Errorf("denied audit entries = %v, want any: %v", logger.denied, tt.wantDenied)
+			}
+			for _, line := range logger.denied {
+				if !strings.Contains(line, tt.caller.userID) {
+					t.Errorf("denied audit entry %q does not name the caller %s", line, tt.caller.userID)
+				}
+			}
+		})
+	}
+}
+
+func ok(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+func TestAuthorize(t *testing.T) {
+	route := func(r *gin.Engine, secured gin.HandlerFunc) {
+		r.POST("/wallets/credit", secured, Authorize(PermWalletCredit), ok)
+	}
+
+	runAuthorizeCases(t, []authorizeCase{
+		{name: "role granting the permission", caller: caller{userID: "u1", roles: []string{RoleFinanceAdmin}}, wantStatus: http.StatusOK},
+		{name: "role names are case insensitive", caller: caller{userID: "u1", roles: []string{"Admin"}}, wantStatus: http.StatusOK},
+		{name: "one of several roles grants it", caller: caller{userID: "u1", roles: []string{"user", RoleAdmin}}, wantStatus: http.StatusOK},
+		{name: "role without the permission", caller: caller{userID: "u1", roles: []string{RoleSupport}}, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "no roles", caller: caller{userID: "u1"}, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "roles from the resolver", caller: caller{userID: "u1"}, resolver: resolveRoles(RoleAdmin), wantStatus: http.StatusOK},
+		{name: "resolver failure", caller: caller{userID: "u1"}, resolver: failResolve, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "services hold no roles", caller: caller{userID: "u1", roles: []string{RoleAdmin}, service: "orders"}, wantStatus: http.StatusForbidden, wantDenied: true},
+	}, route, http.MethodPost, "/wallets/credit")
+}
+
+func TestAuthorizeOwner(t *testing.T) {
+	route := func(r *gin.Engine, secured gin.HandlerFunc) {
+		r.GET("/wallets/:user_id", secured, AuthorizeOwner("user_id", PermWalletReadAny), ok)
+	}
+
+	runAuthorizeCases(t, []authorizeCase{
+		{name: "owner", caller: caller{userID: "u1"}, wantStatus: http.StatusOK},
+		{name: "owner acting through a service", caller: caller{userID: "u1", service: "orders"}, wantStatus: http.StatusOK},
+		{name: "other user with read_any", caller: caller{userID: "u2", roles: []string{RoleSupport}}, wantStatus: http.StatusOK, wantGranted: true},
+		{name: "other user with read_any from the resolver", caller: caller{userID: "u2"}, resolver: resolveRoles(RoleFinanceAdmin), wantStatus: http.StatusOK, wantGranted: true},
+		{name: "other user without read_any", caller: caller{userID: "u2", roles: []string{"user"}}, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "other user without roles", caller: caller{userID: "u2"}, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "service on behalf of another user", caller: caller{userID: "u2", service: "orders"}, wantStatus: http.StatusForbidden, wantDenied: true},
+	}, route, http.MethodGet, "/wallets/u1")
+}
+
+func TestAuthorizeOwnerID(t *testing.T) {
+	route := func(r *gin.Engine, secured gin.HandlerFunc) {
+		r.GET("/orders/:order_id", secured, func(c *gin.Context) {
+			// The order belongs to u1
+			if !AuthorizeOwnerID(c, "u1", PermWalletReadAny) {
+				return
+			}
+			ok(c)
+		})
+	}
+
+	runAuthorizeCases(t, []authorizeCase{
+		{name: "owner", caller: caller{userID: "u1"}, wantStatus: http.StatusOK},
+		{name: "other user with read_any", caller: caller{userID: "u2", roles: []string{RoleAdmin}}, wantStatus: http.StatusOK, wantGranted: true},
+		{name: "other user without read_any", caller: caller{userID: "u2", roles: []string{"user"}}, wantStatus: http.StatusForbidden, wantDenied: true},
+		{name: "resolver failure", caller: caller{userID: "u2"}, resolver: failResolve, wantStatus: http.StatusForbidden, wantDenied: true},
+	}, route, http.MethodGet, "/orders/o1")
+}
+
+func TestCanAccessOwnerLeavesTheResponseToTheHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := setAuthorizeGlobals(t, nil)
+
+	router := gin.New()
+	router.GET("/orders/:order_id", caller{userID: "u2"}.set, func(c *gin.Context) {
+		if !CanAccessOwner(c, "u1", PermWalletReadAny) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		ok(c)
+	})
+
+	w := serve(router, http.MethodGet, "/orders/o1")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if len(logger.denied) != 1 {
+		t.Errorf("denied audit entries = %v, want one", logger.denied)
+	}
+}
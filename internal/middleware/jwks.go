@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMaxAge is how long fetched keys are used before being refetched.
+	jwksMaxAge = 10 * time.Minute
+	// jwksMinRefresh limits refetches triggered by unknown key IDs.
+	jwksMinRefresh = time.Minute
+)
+
+// jwks caches the signing keys of a JWKS document, refetching it when it
+// gets old or a token names a key it does not have, so rotated keys are
+// picked up without a restart.
+type jwks struct {
+	source string
+	client *http.Client
+
+	mu        sync.Mutex
+	byKid     map[string][]crypto.PublicKey
+	all       []crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWKS(source string) *jwks {
+	return &jwks{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// keys returns the keys with the given ID, or every key when kid is empty.
+// A failed refetch keeps serving the keys already loaded.
+func (j *jwks) keys(kid string) []crypto.PublicKey {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	age := time.Since(j.fetchedAt)
+	_, known := j.byKid[kid]
+	if age > jwksMaxAge || (kid != "" && !known && age > jwksMinRefresh) {
+		_ = j.load()
+	}
+
+	if kid == "" {
+		return j.all
+	}
+	return j.byKid[kid]
+}
+
+func (j *jwks) refresh() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.load()
+}
+
+func (j *jwks) load() error {
+
+	// Failed attempts count too, so an unreachable source is not retried
+	// on every request.
+	j.fetchedAt = time.Now()
+
+	data, err := j.read()
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	byKid := map[string][]crypto.PublicKey{}
+	var all []crypto.PublicKey
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		byKid[jwk.Kid] = append(byKid[jwk.Kid], key)
+		all = append(all, key)
+	}
+
+	if len(all) == 0 {
+		return errors.New("jwks has no signing keys")
+	}
+
+	j.byKid = byKid
+	j.all = all
+	return nil
+}
+
+func (j *jwks) read() ([]byte, error) {
+
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(j.source)
+	}
+
+	resp, err := j.client.Get(j.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks source returned status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// publicKey decodes an RSA or EC key; other key types are skipped with a nil
+// key.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -1,38 +1,63 @@
 package middleware
 
 import (
+	"errors"
+	"wallet-service/helper"
 	"wallet-service/pkg/constants"
 	"net/http"
 	"strings"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func Secured() gin.HandlerFunc {
+// Secured authenticates end users by their bearer token. Internal services
+// are only let through when the route names the scopes they need.
+func Secured(scopes ...string) gin.HandlerFunc {
 	return func(context *gin.Context) {
+		if serviceHeader := context.GetHeader(serviceAuthorizationHeader); serviceHeader != "" {
+			authenticateService(context, serviceHeader, scopes)
+			return
+		}
+
 		authorizationHeader := context.GetHeader("Authorization")
 
 		if len(authorizationHeader) == 0 {
-			context.AbortWithStatus(http.StatusForbidden)
+			unauthorized(context, errors.New("authorization header is required"))
 			return
 		}
 		
-		if !strings.HasPrefix(authorizationHeader, "Bearer ") {
-			context.AbortWithStatus(http.StatusUnauthorized)
+		tokenString, ok := strings.CutPrefix(authorizationHeader, "Bearer ")
+		if !ok || tokenString == "" {
+			unauthorized(context, errors.New("authorization header must be a bearer token"))
 			return
 		}
 
-		tokenString := strings.Split(authorizationHeader, " ")[1]
+		if verifier == nil {
+			unauthorized(context, errors.New("token verification is not configured"))
+			return
+		}
 
-		token, _, _ := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+		claims, err := verifier.verify(tokenString)
+		if err != nil {
+			unauthorized(context, tokenError(err))
+			return
+		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if userId, ok := claims[constants.UserID].(string); ok {
-				context.Set(constants.UserID, userId)
-			}
+		userId, ok := claims[constants.UserID].(string)
+		if !ok || userId == "" {
+			unauthorized(context, errors.New("token has no user_id claim"))
+			return
 		}
 
+		context.Set(constants.UserID, userId)
+		if roles := claimRoles(claims); len(roles) > 0 {
+			context.Set(constants.Roles, roles)
+		}
 		context.Set(constants.Token, tokenString)
 		context.Next()
 	}
 }
+
+func unauthorized(context *gin.Context, err error) {
+	helper.SendError(context, http.StatusUnauthorized, err, helper.ErrUnauthorized)
+	context.Abort()
+}
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+	"wallet-service/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testServiceSecret = "service-secret"
+	testServiceIssuer = "https://services.example.com"
+)
+
+// configureTestAuth configures user and service token verification for the
+// test and restores the previous configuration afterwards.
+func configureTestAuth(t *testing.T, allowlist []string) {
+	t.Helper()
+
+	previousVerifier, previousServiceVerifier, previousAllowlist := verifier, serviceVerifier, serviceAllowlist
+	t.Cleanup(func() {
+		verifier, serviceVerifier, serviceAllowlist = previousVerifier, previousServiceVerifier, previousAllowlist
+	})
+
+	if err := Configure(AuthConfig{HMACSecret: testSecret, Issuer: testIssuer, Audience: testAudience}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	err := ConfigureServices(AuthConfig{HMACSecret: testServiceSecret, Issuer: testServiceIssuer, Audience: testAudience}, allowlist)
+	if err != nil {
+		t.Fatalf("ConfigureServices: %v", err)
+	}
+}
+
+func serviceClaims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":          "orders",
+		"iss":          testServiceIssuer,
+		"aud":          testAudience,
+		"exp":          now.Add(time.Hour).Unix(),
+		"on_behalf_of": "u1",
+		"scope":        "wallet.read wallet.deduct",
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+// securedCaller is what Secured left on the context for the handler.
+type securedCaller struct {
+	UserID  string   `json:"user_id"`
+	Service string   `json:"service"`
+	Scopes  []string `json:"scopes"`
+}
+
+func TestSecuredServiceTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configureTestAuth(t, []string{"orders=wallet.read wallet.hold", "billing=wallet.deduct"})
+
+	serviceToken := func(overrides jwt.MapClaims) string {
+		return "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testServiceSecret), "", serviceClaims(overrides))
+	}
+
+	tests := []struct {
+		name          string
+		scopes        []string
+		serviceHeader string
+		userHeader    string
+		wantStatus    int
+		want          securedCaller
+	}{
+		{
+			name:          "allowlisted service on behalf of a user",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(nil),
+			wantStatus:    http.StatusOK,
+			want:          securedCaller{UserID: "u1", Service: "orders", Scopes: []string{ScopeWalletRead}},
+		},
+		{
+			name:          "service not on the allowlist",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"sub": "reports"}),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "missing on_behalf_of",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"on_behalf_of": nil}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "empty on_behalf_of",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"on_behalf_of": ""}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "token scope outside the allowlist is dropped",
+			scopes:        []string{ScopeWalletDeduct},
+			serviceHeader: serviceToken(nil),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "allowlisted scope missing from the token",
+			scopes:        []string{ScopeWalletHold},
+			serviceHeader: serviceToken(nil),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "granted scopes are the intersection",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"scope": "wallet.read wallet.hold wallet.deduct admin"}),
+			wantStatus:    http.StatusOK,
+			want:          securedCaller{UserID: "u1", Service: "orders", Scopes: []string{ScopeWalletRead, ScopeWalletHold}},
+		},
+		{
+			name:          "route without scopes rejects services",
+			serviceHeader: serviceToken(nil),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "wrong issuer",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"iss": testIssuer}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "user token in the service header",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims()),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "not a bearer token",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: "Basic b3JkZXJzOnNlY3JldA==",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "service header takes precedence over a user token",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(nil),
+			userHeader:    "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", withClaims(jwt.MapClaims{"user_id": "u9"})),
+			wantStatus:    http.StatusOK,
+			want:          securedCaller{UserID: "u1", Service: "orders", Scopes: []string{ScopeWalletRead}},
+		},
+		{
+			name:          "rejected service header does not fall back to a user token",
+			scopes:        []string{ScopeWalletRead},
+			serviceHeader: serviceToken(jwt.MapClaims{"sub": "reports"}),
+			userHeader:    "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", withClaims(jwt.MapClaims{"user_id": "u9"})),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:       "user token alone",
+			scopes:     []string{ScopeWalletRead},
+			userHeader: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", withClaims(jwt.MapClaims{"user_id": "u9"})),
+			wantStatus: http.StatusOK,
+			want:       securedCaller{UserID: "u9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/wallets", Secured(tt.scopes...), func(c *gin.Context) {
+				c.JSON(http.StatusOK, securedCaller{
+					UserID:  c.GetString(constants.UserID),
+					Service: c.GetString(constants.Service),
+					Scopes:  c.GetStringSlice(constants.Scopes),
+				})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/wallets", nil)
+			if tt.serviceHeader != "" {
+				req.Header.Set(serviceAuthorizationHeader, tt.serviceHeader)
+			}
+			if tt.userHeader != "" {
+				req.Header.Set("Authorization", tt.userHeader)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got securedCaller
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("caller = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureServices(t *testing.T) {
+	previousVerifier, previousAllowlist := serviceVerifier, serviceAllowlist
+	t.Cleanup(func() {
+		serviceVerifier, serviceAllowlist = previousVerifier, previousAllowlist
+	})
+
+	cfg := AuthConfig{HMACSecret: testServiceSecret, Issuer: testServiceIssuer}
+
+	tests := []struct {
+		name      string
+		cfg       AuthConfig
+		allowlist []string
+		wantErr   bool
+	}{
+		{name: "valid", cfg: cfg, allowlist: []string{"orders=wallet.read wallet.deduct", "reports="}},
+		{name: "no issuer", cfg: AuthConfig{HMACSecret: testServiceSecret}, allowlist: []string{"orders=wallet.read"}, wantErr: true},
+		{name: "entry without scopes separator", cfg: cfg, allowlist: []string{"orders"}, wantErr: true},
+		{name: "entry without a name", cfg: cfg, allowlist: []string{"=wallet.read"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConfigureServices(tt.cfg, tt.allowlist)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConfigureServices error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
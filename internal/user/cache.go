@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxCachedUsers bounds the cache; once reached, expired entries are
+// dropped, and if none are, the cache starts over.
+const maxCachedUsers = 10000
+
+type cachedUser struct {
+	user *UserInfor
+	// err is ErrUserNotFound for a cached negative lookup.
+	err     error
+	expires time.Time
+}
+
+// cachedUserService remembers lookups so hot paths do not call the main
+// service for every request. Users found are kept for ttl and users not
+// found for negativeTTL. While the main service is unavailable, users found
+// before are still served after their entry expires.
+type cachedUserService struct {
+	next        UserService
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu    sync.Mutex
+	users map[string]cachedUser
+}
+
+func NewCachedUserService(next UserService, ttl time.Duration, negativeTTL time.Duration) UserService {
+	return &cachedUserService{
+		next:        next,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		users:       make(map[string]cachedUser),
+	}
+}
+
+func (s *cachedUserService) GetUserInfor(ctx context.Context, userID string) (*UserInfor, error) {
+
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, cached := s.users[userID]
+	s.mu.Unlock()
+
+	if cached && now.Before(entry.expires) {
+		return entry.user, entry.err
+	}
+
+	user, err := s.next.GetUserInfor(ctx, userID)
+	switch {
+	case err == nil:
+		s.store(userID, cachedUser{user: user, expires: now.Add(s.ttl)})
+	case errors.Is(err, ErrUserNotFound):
+		s.store(userID, cachedUser{err: err, expires: now.Add(s.negativeTTL)})
+	case errors.Is(err, ErrUnavailable) && cached && entry.user != nil:
+		return entry.user, nil
+	}
+
+	return user, err
+}
+
+func (s *cachedUserService) store(userID string, entry cachedUser) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.users) >= maxCachedUsers {
+		now := time.Now()
+		for id, cached := range s.users {
+			if !now.Before(cached.expires) {
+				delete(s.users, id)
+			}
+		}
+		if len(s.users) >= maxCachedUsers {
+			s.users = make(map[string]cachedUser)
+		}
+	}
+
+	s.users[userID] = entry
+}
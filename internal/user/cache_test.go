@@ -0,0 +1,145 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// scriptedUsers answers every lookup with err, or a user when err is nil,
+// and counts the lookups.
+type scriptedUsers struct {
+	err   error
+	calls int
+}
+
+func (s *scriptedUsers) GetUserInfor(ctx context.Context, userID string) (*UserInfor, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &UserInfor{UserID: userID, Role: "user"}, nil
+}
+
+func TestCachedUserServiceTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		ttl         time.Duration
+		negativeTTL time.Duration
+		wantCalls   int
+	}{
+		{name: "found user within ttl", ttl: time.Hour, wantCalls: 1},
+		{name: "found user after ttl", ttl: 0, negativeTTL: time.Hour, wantCalls: 2},
+		{name: "missing user within negative ttl", err: ErrUserNotFound, negativeTTL: time.Hour, wantCalls: 1},
+		{name: "missing user after negative ttl", err: ErrUserNotFound, ttl: time.Hour, negativeTTL: 0, wantCalls: 2},
+		{name: "unavailable is not cached", err: ErrUnavailable, ttl: time.Hour, negativeTTL: time.Hour, wantCalls: 2},
+		{name: "other errors are not cached", err: errors.New("bad response"), ttl: time.Hour, negativeTTL: time.Hour, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &scriptedUsers{err: tt.err}
+			s := NewCachedUserService(next, tt.ttl, tt.negativeTTL)
+
+			for i := 0; i < 2; i++ {
+				user, err := s.GetUserInfor(context.Background(), "u1")
+				if tt.err == nil && (err != nil || user == nil || user.UserID != "u1") {
+					t.Fatalf("lookup %d = %v, %v; want user u1", i, user, err)
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("lookup %d error = %v, want %v", i, err, tt.err)
+				}
+			}
+
+			if next.calls != tt.wantCalls {
+				t.Errorf("main service lookups = %d, want %d", next.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedUserServiceServesStaleUserWhileUnavailable(t *testing.T) {
+	next := &scriptedUsers{}
+	s := NewCachedUserService(next, 0, 0)
+	ctx := context.Background()
+
+	if _, err := s.GetUserInfor(ctx, "u1"); err != nil {
+		t.Fatalf("GetUserInfor: %v", err)
+	}
+
+	next.err = ErrUnavailable
+
+	user, err := s.GetUserInfor(ctx, "u1")
+	if err != nil || user == nil || user.UserID != "u1" {
+		t.Errorf("expired user while unavailable = %v, %v; want the cached user", user, err)
+	}
+
+	if _, err := s.GetUserInfor(ctx, "u2"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unknown user while unavailable error = %v, want ErrUnavailable", err)
+	}
+
+	// Once the main service answers again, its answer replaces the stale entry
+	next.err = ErrUserNotFound
+	if _, err := s.GetUserInfor(ctx, "u1"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("lookup after recovery error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCachedUserServiceNeverServesStaleMissingUser(t *testing.T) {
+	next := &scriptedUsers{err: ErrUserNotFound}
+	s := NewCachedUserService(next, 0, 0)
+	ctx := context.Background()
+
+	if _, err := s.GetUserInfor(ctx, "u1"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("GetUserInfor error = %v, want ErrUserNotFound", err)
+	}
+
+	next.err = ErrUnavailable
+	if _, err := s.GetUserInfor(ctx, "u1"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expired missing user while unavailable error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCachedUserServiceIsBounded(t *testing.T) {
+	next := &scriptedUsers{}
+	s := NewCachedUserService(next, 0, 0).(*cachedUserService)
+	ctx := context.Background()
+
+	lookup := func(userID string) {
+		t.Helper()
+		if _, err := s.GetUserInfor(ctx, userID); err != nil {
+			t.Fatalf("GetUserInfor(%s): %v", userID, err)
+		}
+	}
+
+	// Half the cache expires at once, the rest is kept for an hour
+	for i := 0; i < maxCachedUsers/2; i++ {
+		lookup(fmt.Sprintf("expired-%d", i))
+	}
+	s.ttl = time.Hour
+	for i := 0; i < maxCachedUsers-maxCachedUsers/2; i++ {
+		lookup(fmt.Sprintf("live-%d", i))
+	}
+	if len(s.users) != maxCachedUsers {
+		t.Fatalf("cached users = %d, want %d", len(s.users), maxCachedUsers)
+	}
+
+	// A full cache drops its expired entries first
+	lookup("next")
+	if want := maxCachedUsers - maxCachedUsers/2 + 1; len(s.users) != want {
+		t.Fatalf("cached users after dropping expired = %d, want %d", len(s.users), want)
+	}
+
+	for i := 0; len(s.users) < maxCachedUsers; i++ {
+		lookup(fmt.Sprintf("more-%d", i))
+	}
+
+	// With nothing expired, it starts over
+	lookup("last")
+	if len(s.users) != 1 {
+		t.Errorf("cached users after starting over = %d, want 1", len(s.users))
+	}
+}
@@ -0,0 +1,10 @@
+package user
+
+import "errors"
+
+// ErrUserNotFound is returned when the main service does not know the user.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUnavailable is returned when the main service cannot be reached or
+// fails to answer.
+var ErrUnavailable = errors.New("user service unavailable")
@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,11 +16,13 @@ import (
 
 type UserService interface {
 	GetUserInfor(ctx context.Context, userID string) (*UserInfor, error)
-	GetAllUser(ctx context.Context) ([]*UserInfor, error)
 }
 
 type userService struct {
 	client *callAPI
+	// serviceToken is this service's own credential for the main service;
+	// without one, the caller's token is forwarded.
+	serviceToken string
 }
 
 type callAPI struct {
@@ -31,13 +34,28 @@ var (
 	mainService = "go-main-service"
 )
 
-func NewUserService(client *api.Client) UserService {
+func NewUserService(client *api.Client, serviceToken string) UserService {
 	mainServiceAPI := NewServiceAPI(client, mainService)
 	return &userService{
-		client: mainServiceAPI,
+		client:       mainServiceAPI,
+		serviceToken: serviceToken,
 	}
 }
 
+// token returns the credential to call the main service with.
+func (u *userService) token(ctx context.Context) (string, error) {
+	if u.serviceToken != "" {
+		return u.serviceToken, nil
+	}
+
+	token, ok := ctx.Value(constants.TokenKey).(string)
+	if !ok {
+		return "", fmt.Errorf("token not found in context")
+	}
+
+	return token, nil
+}
+
 func NewServiceAPI(client *api.Client, serviceName string) *callAPI {
 	sd, err := consul.NewServiceDiscovery(client, serviceName)
 	if err != nil {
@@ -73,9 +91,9 @@ func NewServiceAPI(client *api.Client, serviceName string) *callAPI {
 
 func (u *userService) GetUserInfor(ctx context.Context, userID string) (*UserInfor, error) {
 
-	token, ok := ctx.Value(constants.TokenKey).(string)
-	if !ok {
-		return nil, fmt.Errorf("token not found in context")
+	token, err := u.token(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	data, err := u.client.GetUserInfor(userID, token)
@@ -84,7 +102,7 @@ func (u *userService) GetUserInfor(ctx context.Context, userID string) (*UserInf
 	}
 
 	if data == nil {
-		return nil, fmt.Errorf("no user data found for userID: %s", userID)
+		return nil, fmt.Errorf("no user data found for userID %s: %w", userID, ErrUserNotFound)
 	}
 
 	innerData, ok := data["data"].(map[string]interface{})
@@ -110,33 +128,6 @@ func (u *userService) GetUserInfor(ctx context.Context, userID string) (*UserInf
 	}, nil
 }
 
-func (u *userService) GetAllUser(ctx context.Context) ([]*UserInfor, error) {
-
-	token, ok := ctx.Value(constants.TokenKey).(string)
-	if !ok {
-		return nil, fmt.Errorf("token not found in context")
-	}
-
-	data := u.client.GetAllUser(token)
-	if data == nil {
-		return nil, fmt.Errorf("no user data found")
-	}
-
-	var users []*UserInfor
-
-	for _, user := range data {
-		userInfor := &UserInfor{
-			UserID:   safeString(user["id"]),
-			UserName: safeString(user["username"]),
-			FullName: safeString(user["fullname"]),
-			Avartar:  safeString(user["avatar"]),
-		}
-		users = append(users, userInfor)
-	}
-
-	return users, nil
-}
-
 func safeString(val interface{}) string {
 	if val == nil {
 		return ""
@@ -157,10 +148,20 @@ func (c *callAPI) GetUserInfor(userID string, token string) (map[string]interfac
 		"Authorization": "Bearer " + token,
 	}
 
+	if c == nil || c.clientServer == nil {
+		return nil, ErrUnavailable
+	}
+
 	res, err := c.client.CallAPI(c.clientServer, endpoint, http.MethodGet, nil, header)
-	if err != nil {
-		fmt.Printf("Error calling API: %v\n", err)
+	var statusErr *consul.StatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", userID, ErrUserNotFound)
+	case errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError:
 		return nil, err
+	case err != nil:
+		fmt.Printf("Error calling API: %v\n", err)
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 
 	var userData interface{}
@@ -175,40 +176,3 @@ func (c *callAPI) GetUserInfor(userID string, token string) (map[string]interfac
 
 	return myMap, nil
 }
-
-func (c *callAPI) GetAllUser(token string) []map[string]interface{} {
-
-	endpoint := "/v1/user/all/?role=all"
-
-	header := map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": "Bearer " + token,
-	}
-
-	res, err := c.client.CallAPI(c.clientServer, endpoint, http.MethodGet, nil, header)
-	if err != nil {
-		fmt.Printf("Error calling API: %v\n", err)
-		return nil
-	}
-
-	var parse map[string]interface{}
-	if err := json.Unmarshal([]byte(res), &parse); err != nil {
-		fmt.Printf("Error calling API: %v\n", err)
-		return nil
-	}
-
-	dataListRaw, ok := parse["data"].([]interface{})
-	if !ok {
-		fmt.Printf("Error calling API: %v\n", err)
-		return nil
-	}
-
-	users := make([]map[string]interface{}, 0)
-
-	for _, item := range dataListRaw {
-		users = append(users, item.(map[string]interface{}))
-	}
-
-	return users
-
-}
@@ -0,0 +1,38 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestInactiveWalletTypeGetsNoWallets(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := env.db.Collection("wallet_types").UpdateOne(ctx, bson.M{"name": "service"},
+		bson.M{"$set": bson.M{"active": false}}); err != nil {
+		t.Fatalf("deactivate service wallet type: %v", err)
+	}
+
+	if err := env.service.CreateWallet(ctx, "u1"); err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	wallets, err := env.repo.GetWalletByUserID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetWalletByUserID: %v", err)
+	}
+	if len(wallets) != 1 || wallets[0].WalletType != "store" {
+		t.Errorf("wallets = %v, want only a store wallet", wallets)
+	}
+
+	_, err = env.service.AddBalance(ctx, &AddBalanceRequest{UserID: "u1", WalletType: "service", Balance: 100, Currency: testCurrency}, "admin")
+	if err == nil {
+		t.Error("AddBalance credited a wallet of an inactive type")
+	}
+	if got := env.count(t, "wallets", bson.M{"user_id": "u1", "wallet_type": "service"}); got != 0 {
+		t.Errorf("service wallets = %d, want 0", got)
+	}
+}
@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestConcurrentDeductionsNeverOverdraw(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// Ten purchases fit in the balance; twenty race for it.
+	env.fund(t, "u1", "store", 1000)
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 100}, "u1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInsufficientFunds):
+			t.Errorf("DeductBalance error = %v, want nil or ErrInsufficientFunds", err)
+		}
+	}
+
+	if succeeded != 10 {
+		t.Errorf("successful purchases = %d, want 10", succeeded)
+	}
+	if got := env.balance(t, "u1", "store"); got != 0 {
+		t.Errorf("store balance = %s, want 0.00", got)
+	}
+}
+
+func TestConcurrentDepositsAreNotLost(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// Create the wallets before the race so every deposit hits an
+	// existing document.
+	if _, err := env.service.GetWalletByUserID(ctx, "u1"); err != nil {
+		t.Fatalf("GetWalletByUserID: %v", err)
+	}
+
+	const deposits = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, deposits)
+	for i := 0; i < deposits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.AddBalance(ctx, &AddBalanceRequest{
+				UserID:     "u1",
+				WalletType: "store",
+				Balance:    50,
+				Currency:   testCurrency,
+			}, "admin")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AddBalance: %v", err)
+		}
+	}
+
+	if got := env.balance(t, "u1", "store"); got != deposits*50 {
+		t.Errorf("store balance = %s, want 10.00", got)
+	}
+	if n := env.count(t, "transactions", bson.M{"user_id": "u1", "type": "deposit"}); n != deposits {
+		t.Errorf("deposit entries = %d, want %d", n, deposits)
+	}
+}
+
+func TestConcurrentTransfersFromDifferentWalletsRespectDailyLimit(t *testing.T) {
+	env := newTestEnvWithSettings(t, Settings{TransferDailyLimit: 1000})
+	ctx := context.Background()
+
+	// Each wallet could pay for every transfer on its own, so only the
+	// daily limit can stop them: five of the twenty fit.
+	env.fund(t, "u1", "store", 5000)
+	env.fund(t, "u1", "service", 5000)
+	if _, err := env.service.GetWalletByUserID(ctx, "u2"); err != nil {
+		t.Fatalf("GetWalletByUserID: %v", err)
+	}
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		walletType := "store"
+		if i%2 == 1 {
+			walletType = "service"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.Transfer(ctx, &TransferRequest{ToUserID: "u2", WalletType: walletType, Amount: 200}, "u1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrTransferLimitExceeded):
+			t.Errorf("Transfer error = %v, want nil or ErrTransferLimitExceeded", err)
+		}
+	}
+
+	if succeeded != 5 {
+		t.Errorf("successful transfers = %d, want 5", succeeded)
+	}
+	if n := env.count(t, "transactions", bson.M{"user_id": "u1", "type": "transfer_out"}); n != 5 {
+		t.Errorf("transfer_out entries = %d, want 5", n)
+	}
+}
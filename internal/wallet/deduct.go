@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wallet-service/internal/wallettype"
+	"wallet-service/pkg/money"
+)
+
+// debit is one wallet's share of a purchase.
+type debit struct {
+	walletType *wallettype.WalletType
+	amount     money.Amount
+}
+
+// deductionPlan is a validated DeductBalanceRequest. Either debits is fixed
+// up front, or priority lists the wallet types to drain in order and the
+// split is made once balances are read inside the transaction.
+type deductionPlan struct {
+	debits   []debit
+	priority []*wallettype.WalletType
+}
+
+// planDeduction validates whichever of the three request forms was used:
+// the legacy PriceStore/PriceService fields, explicit Legs, or a single
+// Amount with a Priority order.
+func (s *walletService) planDeduction(ctx context.Context, req *DeductBalanceRequest) (*deductionPlan, error) {
+
+	legacy := req.PriceStore != 0 || req.PriceService != 0
+	forms := 0
+	for _, used := range []bool{legacy, len(req.Legs) > 0, req.Amount != 0 || len(req.Priority) > 0} {
+		if used {
+			forms++
+		}
+	}
+
+	if forms == 0 {
+		return nil, fmt.Errorf("legs, amount with priority, or a price is required")
+	}
+
+	if forms > 1 {
+		return nil, fmt.Errorf("use only one of legs, amount with priority, or price_store/price_service")
+	}
+
+	var plan *deductionPlan
+	var err error
+	if legacy {
+		plan, err = s.planLegacyDeduction(ctx, req)
+	} else {
+		plan, err = s.planGenericDeduction(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A purchase is charged in one currency; its total and the revenue
+	// posting would mean nothing across several.
+	walletTypes := append([]*wallettype.WalletType{}, plan.priority...)
+	for _, debit := range plan.debits {
+		walletTypes = append(walletTypes, debit.walletType)
+	}
+	for _, walletType := range walletTypes {
+		if walletType.Currency != walletTypes[0].Currency {
+			return nil, fmt.Errorf("cannot charge %s and %s wallets of different currencies in one purchase", walletTypes[0].Name, walletType.Name)
+		}
+	}
+
+	return plan, nil
+}
+
+// planGenericDeduction validates the Legs and Amount with Priority forms.
+func (s *walletService) planGenericDeduction(ctx context.Context, req *DeductBalanceRequest) (*deductionPlan, error) {
+
+	if req.SpendOn == "" {
+		return nil, fmt.Errorf("spend_on is required")
+	}
+
+	if len(req.Legs) > 0 {
+		plan := &deductionPlan{}
+		seen := make(map[string]bool)
+		for _, leg := range req.Legs {
+			if leg.Amount <= 0 {
+				return nil, fmt.Errorf("leg amount must be greater than 0")
+			}
+			if seen[leg.WalletType] {
+				return nil, fmt.Errorf("duplicate leg for %s wallet", leg.WalletType)
+			}
+			seen[leg.WalletType] = true
+
+			walletType, err := s.spendableWalletType(ctx, leg.WalletType, req.SpendOn)
+			if err != nil {
+				return nil, err
+			}
+
+			plan.debits = append(plan.debits, debit{walletType: walletType, amount: leg.Amount})
+		}
+		return plan, nil
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+
+	if len(req.Priority) == 0 {
+		return nil, fmt.Errorf("priority is required with amount")
+	}
+
+	plan := &deductionPlan{}
+	seen := make(map[string]bool)
+	for _, name := range req.Priority {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate wallet type %s in priority", name)
+		}
+		seen[name] = true
+
+		walletType, err := s.spendableWalletType(ctx, name, req.SpendOn)
+		if err != nil {
+			return nil, err
+		}
+
+		plan.priority = append(plan.priority, walletType)
+	}
+
+	return plan, nil
+}
+
+// planLegacyDeduction pays each price from the wallet type of the same
+// name, which the catalog must allow to be spent on that purpose.
+func (s *walletService) planLegacyDeduction(ctx context.Context, req *DeductBalanceRequest) (*deductionPlan, error) {
+
+	if req.PriceService < 0 || req.PriceStore < 0 {
+		return nil, fmt.Errorf("prices cannot be negative")
+	}
+
+	plan := &deductionPlan{}
+	for _, purpose := range []struct {
+		name  string
+		price money.Amount
+	}{
+		{name: "service", price: req.PriceService},
+		{name: "store", price: req.PriceStore},
+	} {
+		if purpose.price == 0 {
+			continue
+		}
+
+		walletType, err := s.spendableWalletType(ctx, purpose.name, purpose.name)
+		if err != nil {
+			return nil, err
+		}
+
+		plan.debits = append(plan.debits, debit{walletType: walletType, amount: purpose.price})
+	}
+
+	return plan, nil
+}
+
+func (s *walletService) spendableWalletType(ctx context.Context, name string, purpose string) (*wallettype.WalletType, error) {
+
+	walletType, err := s.activeWalletType(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !walletType.CanSpendOn(purpose) {
+		return nil, fmt.Errorf("%s wallet cannot be spent on %s", walletType.Name, purpose)
+	}
+
+	return walletType, nil
+}
+
+// splitByPriority takes amount from the wallets in priority order, each
+// giving up to its available balance. A wallet type allowed to go negative absorbs
+// whatever is left when its turn comes.
+func splitByPriority(userID string, wallets []*Wallet, priority []*wallettype.WalletType, amount money.Amount) ([]debit, error) {
+
+	byType := make(map[string]*Wallet)
+	for _, wallet := range wallets {
+		byType[wallet.WalletType] = wallet
+	}
+
+	var debits []debit
+	remaining := amount
+
+	for _, walletType := range priority {
+		if remaining == 0 {
+			break
+		}
+
+		wallet, ok := byType[walletType.Name]
+		if !ok {
+			continue
+		}
+
+		take := wallet.Balance - wallet.HeldBalance
+		if walletType.AllowNegative || take > remaining {
+			take = remaining
+		}
+		if take <= 0 {
+			continue
+		}
+
+		debits = append(debits, debit{walletType: walletType, amount: take})
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		names := make([]string, len(priority))
+		for i, walletType := range priority {
+			names[i] = walletType.Name
+		}
+		return nil, &InsufficientFundsError{UserID: userID, WalletType: strings.Join(names, ", "), Amount: amount}
+	}
+
+	return debits, nil
+}
+
+// newDeduction summarises the entries of one purchase.
+func newDeduction(transactions []*Transactions) *Deduction {
+
+	deduction := &Deduction{Transactions: transactions}
+
+	for _, transaction := range transactions {
+		deduction.OperationID = transaction.OperationID
+		deduction.Currency = transaction.WalletCurrency
+		deduction.Total -= transaction.Amount
+		deduction.Breakdown = append(deduction.Breakdown, DeductLeg{
+			WalletType: transaction.WalletType,
+			Amount:     -transaction.Amount,
+		})
+	}
+
+	return deduction
+}
@@ -0,0 +1,359 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"wallet-service/internal/wallettype"
+	"wallet-service/pkg/money"
+)
+
+// fakeWalletTypes is an in-memory catalog keyed by name.
+type fakeWalletTypes map[string]*wallettype.WalletType
+
+func (f fakeWalletTypes) CreateWalletType(ctx context.Context, req *wallettype.CreateWalletTypeRequest) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f fakeWalletTypes) GetAllWalletType(ctx context.Context) ([]*wallettype.WalletType, error) {
+	var walletTypes []*wallettype.WalletType
+	for _, walletType := range f {
+		walletTypes = append(walletTypes, walletType)
+	}
+	return walletTypes, nil
+}
+
+func (f fakeWalletTypes) GetActiveWalletTypes(ctx context.Context) ([]*wallettype.WalletType, error) {
+	var walletTypes []*wallettype.WalletType
+	for _, walletType := range f {
+		if walletType.Active {
+			walletTypes = append(walletTypes, walletType)
+		}
+	}
+	return walletTypes, nil
+}
+
+func (f fakeWalletTypes) GetWalletType(ctx context.Context, id string) (*wallettype.WalletType, error) {
+	return nil, wallettype.ErrWalletTypeNotFound
+}
+
+func (f fakeWalletTypes) GetWalletTypeByName(ctx context.Context, name string) (*wallettype.WalletType, error) {
+	walletType, ok := f[name]
+	if !ok {
+		return nil, wallettype.ErrWalletTypeNotFound
+	}
+	return walletType, nil
+}
+
+func (f fakeWalletTypes) UpdateWalletType(ctx context.Context, id string, req *wallettype.UpdateWalletTypeRequest) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f fakeWalletTypes) DeleteWalletType(ctx context.Context, id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func testCatalog() fakeWalletTypes {
+	return fakeWalletTypes{
+		"store":   {Name: "store", Currency: testCurrency, SpendableOn: []string{"store"}, Active: true},
+		"service": {Name: "service", Currency: testCurrency, SpendableOn: []string{"service"}, Active: true},
+		"bonus":   {Name: "bonus", Currency: testCurrency, SpendableOn: []string{"store", "service"}, Active: true},
+		"usd":     {Name: "usd", Currency: "USD", SpendableOn: []string{"store"}, Active: true},
+		"retired": {Name: "retired", Currency: testCurrency, SpendableOn: []string{"store"}},
+	}
+}
+
+// describePlan renders a plan as "name:amount" debits and priority names.
+func describePlan(plan *deductionPlan) (debits []string, priority []string) {
+	for _, debit := range plan.debits {
+		debits = append(debits, fmt.Sprintf("%s:%d", debit.walletType.Name, debit.amount))
+	}
+	for _, walletType := range plan.priority {
+		priority = append(priority, walletType.Name)
+	}
+	return debits, priority
+}
+
+func TestPlanDeduction(t *testing.T) {
+	s := &walletService{walletTypeService: testCatalog()}
+
+	tests := []struct {
+		name         string
+		req          DeductBalanceRequest
+		wantDebits   []string
+		wantPriority []string
+		wantErr      bool
+	}{
+		{
+			name:    "no form",
+			req:     DeductBalanceRequest{SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "legacy and legs together",
+			req:     DeductBalanceRequest{PriceStore: 100, Legs: []DeductLeg{{WalletType: "store", Amount: 100}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "legs and amount together",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "store", Amount: 100}}, Amount: 100, Priority: []string{"store"}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:       "legacy both prices",
+			req:        DeductBalanceRequest{PriceStore: 100, PriceService: 50},
+			wantDebits: []string{"service:50", "store:100"},
+		},
+		{
+			name:       "legacy store only",
+			req:        DeductBalanceRequest{PriceStore: 100},
+			wantDebits: []string{"store:100"},
+		},
+		{
+			name:    "legacy negative price",
+			req:     DeductBalanceRequest{PriceStore: 100, PriceService: -1},
+			wantErr: true,
+		},
+		{
+			name:       "legs",
+			req:        DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "bonus", Amount: 30}, {WalletType: "store", Amount: 70}}, SpendOn: "store"},
+			wantDebits: []string{"bonus:30", "store:70"},
+		},
+		{
+			name:    "legs without spend_on",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "store", Amount: 70}}},
+			wantErr: true,
+		},
+		{
+			name:    "leg of zero",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "store", Amount: 0}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate leg",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "store", Amount: 10}, {WalletType: "store", Amount: 20}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "leg not spendable on purpose",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "service", Amount: 10}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "leg of unknown wallet type",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "gems", Amount: 10}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "leg of inactive wallet type",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "retired", Amount: 10}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:         "amount with priority",
+			req:          DeductBalanceRequest{Amount: 100, Priority: []string{"bonus", "store"}, SpendOn: "store"},
+			wantPriority: []string{"bonus", "store"},
+		},
+		{
+			name:    "amount without priority",
+			req:     DeductBalanceRequest{Amount: 100, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "priority without amount",
+			req:     DeductBalanceRequest{Priority: []string{"store"}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate priority",
+			req:     DeductBalanceRequest{Amount: 100, Priority: []string{"store", "store"}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "legs of different currencies",
+			req:     DeductBalanceRequest{Legs: []DeductLeg{{WalletType: "store", Amount: 10}, {WalletType: "usd", Amount: 10}}, SpendOn: "store"},
+			wantErr: true,
+		},
+		{
+			name:    "priority of different currencies",
+			req:     DeductBalanceRequest{Amount: 100, Priority: []string{"usd", "bonus"}, SpendOn: "store"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := s.planDeduction(context.Background(), &tt.req)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("planDeduction accepted the request, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planDeduction: %v", err)
+			}
+
+			debits, priority := describePlan(plan)
+			if !reflect.DeepEqual(debits, tt.wantDebits) {
+				t.Errorf("debits = %v, want %v", debits, tt.wantDebits)
+			}
+			if !reflect.DeepEqual(priority, tt.wantPriority) {
+				t.Errorf("priority = %v, want %v", priority, tt.wantPriority)
+			}
+		})
+	}
+}
+
+func TestPlanLegacyDeductionNeedsSpendableCatalog(t *testing.T) {
+	catalog := testCatalog()
+	catalog["store"] = &wallettype.WalletType{Name: "store", Currency: testCurrency, SpendableOn: []string{"service"}, Active: true}
+	s := &walletService{walletTypeService: catalog}
+
+	if _, err := s.planLegacyDeduction(context.Background(), &DeductBalanceRequest{PriceStore: 100}); err == nil {
+		t.Error("planLegacyDeduction accepted a store price from a wallet not spendable on store")
+	}
+
+	plan, err := s.planLegacyDeduction(context.Background(), &DeductBalanceRequest{PriceService: 100})
+	if err != nil {
+		t.Fatalf("planLegacyDeduction: %v", err)
+	}
+	if debits, _ := describePlan(plan); !reflect.DeepEqual(debits, []string{"service:100"}) {
+		t.Errorf("debits = %v, want [service:100]", debits)
+	}
+}
+
+func TestPlanGenericDeductionResolvesEveryPriority(t *testing.T) {
+	s := &walletService{walletTypeService: testCatalog()}
+
+	_, err := s.planGenericDeduction(context.Background(), &DeductBalanceRequest{Amount: 100, Priority: []string{"store", "gems"}, SpendOn: "store"})
+	if err == nil {
+		t.Error("planGenericDeduction accepted an unknown wallet type in priority")
+	}
+}
+
+func TestSplitByPriority(t *testing.T) {
+	catalog := testCatalog()
+	overdraft := &wallettype.WalletType{Name: "credit", Currency: testCurrency, SpendableOn: []string{"store"}, AllowNegative: true, Active: true}
+
+	wallet := func(walletType string, balance, held money.Amount) *Wallet {
+		return &Wallet{UserID: "u1", WalletType: walletType, Balance: balance, HeldBalance: held}
+	}
+
+	tests := []struct {
+		name       string
+		wallets    []*Wallet
+		priority   []*wallettype.WalletType
+		amount     money.Amount
+		wantDebits []string
+		wantErr    bool
+	}{
+		{
+			name:       "first wallet fits exactly",
+			wallets:    []*Wallet{wallet("bonus", 100, 0), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"bonus:100"},
+		},
+		{
+			name:       "spills over to the next priority",
+			wallets:    []*Wallet{wallet("bonus", 30, 0), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"bonus:30", "store:70"},
+		},
+		{
+			name:       "all wallets drained exactly",
+			wallets:    []*Wallet{wallet("bonus", 30, 0), wallet("store", 70, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"bonus:30", "store:70"},
+		},
+		{
+			name:       "order follows priority not wallets",
+			wallets:    []*Wallet{wallet("bonus", 500, 0), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["store"], catalog["bonus"]},
+			amount:     100,
+			wantDebits: []string{"store:100"},
+		},
+		{
+			name:       "equal balances take from the first listed",
+			wallets:    []*Wallet{wallet("bonus", 60, 0), wallet("store", 60, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"bonus:60", "store:40"},
+		},
+		{
+			name:       "zero balance wallet is skipped",
+			wallets:    []*Wallet{wallet("bonus", 0, 0), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"store:100"},
+		},
+		{
+			name:       "fully held wallet is skipped",
+			wallets:    []*Wallet{wallet("bonus", 100, 100), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"store:100"},
+		},
+		{
+			name:       "held funds are not taken",
+			wallets:    []*Wallet{wallet("bonus", 100, 60), wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"bonus:40", "store:60"},
+		},
+		{
+			name:       "missing wallet is skipped",
+			wallets:    []*Wallet{wallet("store", 500, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:     100,
+			wantDebits: []string{"store:100"},
+		},
+		{
+			name:       "negative balance wallet absorbs the rest",
+			wallets:    []*Wallet{wallet("bonus", 30, 0), wallet("credit", 0, 0)},
+			priority:   []*wallettype.WalletType{catalog["bonus"], overdraft},
+			amount:     100,
+			wantDebits: []string{"bonus:30", "credit:70"},
+		},
+		{
+			name:     "insufficient total funds",
+			wallets:  []*Wallet{wallet("bonus", 30, 0), wallet("store", 60, 0)},
+			priority: []*wallettype.WalletType{catalog["bonus"], catalog["store"]},
+			amount:   100,
+			wantErr:  true,
+		},
+		{
+			name:     "no wallets",
+			priority: []*wallettype.WalletType{catalog["store"]},
+			amount:   100,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debits, err := splitByPriority("u1", tt.wallets, tt.priority, tt.amount)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInsufficientFunds) {
+					t.Fatalf("splitByPriority error = %v, want ErrInsufficientFunds", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitByPriority: %v", err)
+			}
+
+			got, _ := describePlan(&deductionPlan{debits: debits})
+			if !reflect.DeepEqual(got, tt.wantDebits) {
+				t.Errorf("debits = %v, want %v", got, tt.wantDebits)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"wallet-service/internal/ledger"
+)
+
+func TestAddBalanceDefaultsToWalletCurrency(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	transaction, err := env.service.AddBalance(ctx, &AddBalanceRequest{UserID: "u1", WalletType: "store", Balance: 250}, "admin")
+	if err != nil {
+		t.Fatalf("AddBalance without currency: %v", err)
+	}
+	if transaction.Currency == nil || *transaction.Currency != testCurrency {
+		t.Errorf("deposit currency = %v, want %s", transaction.Currency, testCurrency)
+	}
+	if got := env.balance(t, "u1", "store"); got != 250 {
+		t.Errorf("store balance = %s, want 2.50", got)
+	}
+
+	ledgerService := ledger.NewLedgerService(ledger.NewLedgerRepository(env.db.Collection("journal_entries")))
+	trial, err := ledgerService.GetTrialBalance(ctx)
+	if err != nil {
+		t.Fatalf("GetTrialBalance: %v", err)
+	}
+	if len(trial.Currencies) != 1 || trial.Currencies[0].Currency != testCurrency {
+		t.Fatalf("trial balance currencies = %+v, want only %s", trial.Currencies, testCurrency)
+	}
+	if !trial.Balanced || trial.Currencies[0].TotalCredit != 250 {
+		t.Errorf("trial balance = %+v, want balanced with 2.50 credited", trial.Currencies[0])
+	}
+}
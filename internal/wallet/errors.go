@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"wallet-service/pkg/money"
+)
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is replayed
+// with a request body that differs from the one it was first used with.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// ErrRefundExceedsCharge is returned when a refund would give back more than
+// the purchase entry took from the wallet.
+var ErrRefundExceedsCharge = errors.New("refund exceeds the amount charged")
+
+// ErrOrderAlreadyCharged is returned when a purchase names an order that
+// has already been paid.
+var ErrOrderAlreadyCharged = errors.New("order has already been charged")
+
+// ErrOrderNotFound is returned when no purchase was charged for an order.
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrHoldNotActive is returned when a hold has already been captured,
+// released or has expired.
+var ErrHoldNotActive = errors.New("hold is no longer active")
+
+// ErrTransferLimitExceeded is returned when a transfer is over the
+// per-transfer or daily limit.
+var ErrTransferLimitExceeded = errors.New("transfer limit exceeded")
+
+// ErrInsufficientFunds is matched by every InsufficientFundsError through errors.Is.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// InsufficientFundsError is returned when a debit matched no wallet because the
+// balance was lower than the requested amount at the time of the update.
+type InsufficientFundsError struct {
+	UserID     string
+	WalletType string
+	Amount     money.Amount
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds in %s wallet of user %s: need %s", e.WalletType, e.UserID, e.Amount)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
@@ -2,14 +2,21 @@ package wallet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"wallet-service/helper"
+	"wallet-service/internal/exchange"
+	"wallet-service/internal/middleware"
+	"wallet-service/internal/user"
 	"wallet-service/pkg/constants"
 
 	"github.com/gin-gonic/gin"
 )
 
+// idempotencyKeyHeader lets callers safely retry balance mutations.
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type WalletHandler struct {
 	service WalletService
 }
@@ -38,9 +45,16 @@ func (h *WalletHandler) GetWalletByUserID(c *gin.Context) {
 	
 	user_id := c.Param("user_id")
 
-	wallet, err := h.service.GetWalletByUserID(c, user_id)
+	var wallet *WalletByUser
+	var err error
+
+	if currency := c.Query("display_currency"); currency != "" {
+		wallet, err = h.service.GetWalletInCurrency(c, user_id, currency)
+	} else {
+		wallet, err = h.service.GetWalletByUserID(c, user_id)
+	}
 	if err != nil {
-		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		sendWalletError(c, err)
 		return
 	}
 
@@ -48,6 +62,47 @@ func (h *WalletHandler) GetWalletByUserID(c *gin.Context) {
 	
 }
 
+func (h *WalletHandler) GetTransactions(c *gin.Context) {
+
+	user_id := c.Param("user_id")
+
+	var filter TransactionFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	page, err := h.service.GetTransactions(c, user_id, &filter)
+	if err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", page)
+
+}
+
+func (h *WalletHandler) GetOrderTransactions(c *gin.Context) {
+
+	order_id := c.Param("order_id")
+
+	order, err := h.service.GetOrderTransactions(c, order_id)
+	if err != nil && !errors.Is(err, ErrOrderNotFound) {
+		sendWalletError(c, err)
+		return
+	}
+
+	// Orders of other users look the same as orders that do not exist
+	if err != nil || !middleware.CanAccessOwner(c, order.Order.UserID, middleware.PermWalletReadAny) {
+		helper.SendError(c, http.StatusNotFound, ErrOrderNotFound, helper.ErrNotFound)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", order)
+
+}
+
 func (h *WalletHandler) AddBalance(c *gin.Context) {
 	
 	var req AddBalanceRequest
@@ -63,13 +118,15 @@ func (h *WalletHandler) AddBalance(c *gin.Context) {
 		return
 	}
 
-	err := h.service.AddBalance(c, &req, userID.(string))
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	transaction, err := h.service.AddBalance(c, &req, userID.(string))
 	if err != nil {
-		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		sendWalletError(c, err)
 		return
 	}
 
-	helper.SendSuccess(c, http.StatusOK, "success", nil)
+	helper.SendSuccess(c, http.StatusOK, "success", transaction)
 
 }
 
@@ -82,6 +139,144 @@ func (h *WalletHandler) DeductBalance(c *gin.Context) {
 		return
 	}
 
+	// Services acting on behalf of a user have no user token to forward
+	var ctx context.Context = c
+	if token, exists := c.Get(constants.Token); exists {
+		ctx = context.WithValue(c, constants.TokenKey, token)
+	}
+
+	userID, exists := c.Get(constants.UserID)
+
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
+
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	deduction, err := h.service.DeductBalance(ctx, &req, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", deduction)
+
+}
+
+func (h *WalletHandler) Refund(c *gin.Context) {
+
+	var req RefundRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	userID, exists := c.Get(constants.UserID)
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
+
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	transactions, err := h.service.Refund(c, &req, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", transactions)
+
+}
+
+func (h *WalletHandler) CreateHold(c *gin.Context) {
+
+	var req CreateHoldRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	userID, exists := c.Get(constants.UserID)
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
+
+	hold, err := h.service.CreateHold(c, &req, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", hold)
+
+}
+
+func (h *WalletHandler) CaptureHold(c *gin.Context) {
+
+	hold_id := c.Param("hold_id")
+
+	var req CaptureHoldRequest
+
+	// The body is optional: an empty body captures the whole hold
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+			return
+		}
+	}
+
+	userID, exists := c.Get(constants.UserID)
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
+
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	transactions, err := h.service.CaptureHold(c, hold_id, &req, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", transactions)
+
+}
+
+func (h *WalletHandler) ReleaseHold(c *gin.Context) {
+
+	hold_id := c.Param("hold_id")
+
+	userID, exists := c.Get(constants.UserID)
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
+
+	hold, err := h.service.ReleaseHold(c, hold_id, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", hold)
+
+}
+
+func (h *WalletHandler) Transfer(c *gin.Context) {
+
+	var req TransferRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
 	token, exists := c.Get(constants.Token)
 	if !exists {
 		helper.SendError(c, 400, fmt.Errorf("token not found"), helper.ErrInvalidRequest)
@@ -91,18 +286,71 @@ func (h *WalletHandler) DeductBalance(c *gin.Context) {
 	ctx := context.WithValue(c, constants.TokenKey, token)
 
 	userID, exists := c.Get(constants.UserID)
-
 	if !exists {
 		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
 		return
 	}
 
-	err := h.service.DeductBalance(ctx, &req, userID.(string))
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	transactions, err := h.service.Transfer(ctx, &req, userID.(string))
 	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", transactions)
+
+}
+
+func (h *WalletHandler) InternalTransfer(c *gin.Context) {
+
+	var req InternalTransferRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
 		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
 		return
 	}
 
-	helper.SendSuccess(c, http.StatusOK, "success", nil)
+	userID, exists := c.Get(constants.UserID)
+	if !exists {
+		helper.SendError(c, 400, fmt.Errorf("user_id not found"), helper.ErrInvalidRequest)
+		return
+	}
 
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
+
+	transactions, err := h.service.InternalTransfer(c, &req, userID.(string))
+	if err != nil {
+		sendWalletError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", transactions)
+
+}
+
+// sendWalletError maps the wallet package's typed errors to their status
+// codes; anything else is reported as a bad request.
+func sendWalletError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		helper.SendError(c, http.StatusUnprocessableEntity, err, helper.ErrInsufficientFunds)
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		helper.SendError(c, http.StatusConflict, err, helper.ErrIdempotencyConflict)
+	case errors.Is(err, ErrRefundExceedsCharge):
+		helper.SendError(c, http.StatusUnprocessableEntity, err, helper.ErrRefundExceedsCharge)
+	case errors.Is(err, ErrOrderAlreadyCharged):
+		helper.SendError(c, http.StatusConflict, err, helper.ErrOrderAlreadyCharged)
+	case errors.Is(err, ErrHoldNotActive):
+		helper.SendError(c, http.StatusConflict, err, helper.ErrHoldNotActive)
+	case errors.Is(err, ErrTransferLimitExceeded):
+		helper.SendError(c, http.StatusUnprocessableEntity, err, helper.ErrTransferLimit)
+	case errors.Is(err, exchange.ErrRateNotFound):
+		helper.SendError(c, http.StatusUnprocessableEntity, err, helper.ErrRateNotFound)
+	case errors.Is(err, user.ErrUnavailable):
+		helper.SendError(c, http.StatusServiceUnavailable, err, helper.ErrServiceUnavailable)
+	default:
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,248 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/ledger"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultHoldTTL = 72 * time.Hour
+	maxHoldTTL     = 30 * 24 * time.Hour
+)
+
+// CreateHold reserves funds on one of the caller's wallets. Held funds stay
+// in Balance but can no longer be spent until the hold is captured, released
+// or expires.
+func (s *walletService) CreateHold(ctx context.Context, req *CreateHoldRequest, userID string) (*Hold, error) {
+	// Validate input
+	if req == nil {
+		return nil, fmt.Errorf("request is required")
+	}
+
+	if userID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+
+	if req.WalletType == "" {
+		return nil, fmt.Errorf("wallet_type is required")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+
+	if _, err := s.activeWalletType(ctx, req.WalletType); err != nil {
+		return nil, err
+	}
+
+	ttl := defaultHoldTTL
+	if req.ExpiresIn < 0 {
+		return nil, fmt.Errorf("expires_in cannot be negative")
+	}
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > maxHoldTTL {
+		return nil, fmt.Errorf("expires_in cannot exceed %d seconds", int64(maxHoldTTL.Seconds()))
+	}
+
+	var orderID *primitive.ObjectID
+	if req.OrderID != "" {
+		id, err := primitive.ObjectIDFromHex(req.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order_id: %w", err)
+		}
+		orderID = &id
+	}
+
+	_, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hold := &Hold{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		WalletType: req.WalletType,
+		Amount:     req.Amount,
+		Status:     HoldStatusActive,
+		OrderID:    orderID,
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err = s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := s.walletRepo.HoldBalance(ctx, userID, req.WalletType, req.Amount); err != nil {
+			return fmt.Errorf("failed to hold %s wallet: %w", req.WalletType, err)
+		}
+
+		return s.walletRepo.CreateHold(ctx, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold debits all or part of a hold and releases the rest. The
+// captured part is recorded as a purchase, charging the hold's order if it
+// has one.
+func (s *walletService) CaptureHold(ctx context.Context, holdID string, req *CaptureHoldRequest, userID string) ([]*Transactions, error) {
+	// Validate input
+	if req == nil {
+		return nil, fmt.Errorf("request is required")
+	}
+
+	hold, err := s.getActiveHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	capture := hold.Amount
+	if req.Amount != nil {
+		capture = *req.Amount
+	}
+
+	if capture <= 0 || capture > hold.Amount {
+		return nil, fmt.Errorf("capture amount must be between 0 and %s", hold.Amount)
+	}
+
+	release := hold.Amount - capture
+
+	return s.runIdempotent(ctx, userID, operationCaptureHold, req.IdempotencyKey, struct {
+		HoldID string
+		*CaptureHoldRequest
+	}{holdID, req}, func(ctx context.Context) ([]*Transactions, error) {
+
+		operationID := primitive.NewObjectID()
+
+		if err := s.walletRepo.CloseHold(ctx, hold.ID, HoldStatusCaptured, capture); err != nil {
+			return nil, err
+		}
+
+		if hold.OrderID != nil {
+			err := s.walletRepo.CreateOrderCharge(ctx, &OrderCharge{
+				OrderID:     *hold.OrderID,
+				OperationID: operationID,
+				UserID:      hold.UserID,
+				CreatedAt:   time.Now(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		wallet, err := s.walletRepo.CaptureHeldBalance(ctx, hold.UserID, hold.WalletType, capture, release)
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture %s wallet: %w", hold.WalletType, err)
+		}
+
+		transaction := newLedgerEntry(operationID, "purchase", wallet, -capture)
+		transaction.OrderID = hold.OrderID
+		transaction.HoldID = &hold.ID
+		transaction.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+		err = s.walletRepo.CreateTransaction(ctx, transaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transaction: %w", err)
+		}
+
+		postings := ledger.Transfer(ledger.WalletAccount(hold.UserID, hold.WalletType), ledger.RevenueAccount, capture)
+		if _, err := s.ledgerService.Post(ctx, operationID, "purchase", wallet.Currency, postings); err != nil {
+			return nil, err
+		}
+
+		return []*Transactions{transaction}, nil
+	})
+}
+
+// ReleaseHold cancels a hold and makes its funds available again.
+func (s *walletService) ReleaseHold(ctx context.Context, holdID string, userID string) (*Hold, error) {
+
+	hold, err := s.getActiveHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.closeHold(ctx, hold, HoldStatusReleased)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldStatusReleased
+	return hold, nil
+}
+
+// ExpireHolds releases every active hold past its expiry and returns how
+// many were expired.
+func (s *walletService) ExpireHolds(ctx context.Context) (int, error) {
+
+	holds, err := s.walletRepo.GetExpiredHolds(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired holds: %w", err)
+	}
+
+	expired := 0
+	for _, hold := range holds {
+		err := s.closeHold(ctx, hold, HoldStatusExpired)
+		if errors.Is(err, ErrHoldNotActive) {
+			// Captured or released since it was listed
+			continue
+		}
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire hold %s: %w", hold.ID.Hex(), err)
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+func (s *walletService) closeHold(ctx context.Context, hold *Hold, status string) error {
+	return s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if err := s.walletRepo.CloseHold(ctx, hold.ID, status, 0); err != nil {
+			return err
+		}
+
+		_, err := s.walletRepo.ReleaseHeldBalance(ctx, hold.UserID, hold.WalletType, hold.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to release %s wallet: %w", hold.WalletType, err)
+		}
+
+		return nil
+	})
+}
+
+// getActiveHold loads a hold owned by userID that can still be settled.
+func (s *walletService) getActiveHold(ctx context.Context, holdID string, userID string) (*Hold, error) {
+
+	id, err := primitive.ObjectIDFromHex(holdID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hold_id: %w", err)
+	}
+
+	hold, err := s.walletRepo.GetHold(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	if hold.UserID != userID {
+		return nil, fmt.Errorf("hold %s does not belong to user %s", holdID, userID)
+	}
+
+	if hold.Status != HoldStatusActive || !time.Now().Before(hold.ExpiresAt) {
+		return nil, ErrHoldNotActive
+	}
+
+	return hold, nil
+}
@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func (e *testEnv) held(t *testing.T, userID string, walletType string) money.Amount {
+	t.Helper()
+
+	wallet, err := e.repo.GetBalanceUser(context.Background(), userID, walletType)
+	if err != nil {
+		t.Fatalf("get %s %s wallet: %v", userID, walletType, err)
+	}
+	return wallet.HeldBalance
+}
+
+func TestDeductBalanceCannotSpendHeldFunds(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	if _, err := env.service.CreateHold(ctx, &CreateHoldRequest{WalletType: "store", Amount: 700}, "u1"); err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+
+	_, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 400}, "u1")
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("DeductBalance over available error = %v, want ErrInsufficientFunds", err)
+	}
+
+	if _, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 300}, "u1"); err != nil {
+		t.Fatalf("DeductBalance within available: %v", err)
+	}
+	if got := env.balance(t, "u1", "store"); got != 700 {
+		t.Errorf("store balance = %s, want 7.00", got)
+	}
+	if got := env.held(t, "u1", "store"); got != 700 {
+		t.Errorf("held balance = %s, want 7.00", got)
+	}
+}
+
+func TestCaptureHoldIsIdempotent(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	hold, err := env.service.CreateHold(ctx, &CreateHoldRequest{WalletType: "store", Amount: 700}, "u1")
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+
+	amount := money.Amount(400)
+	req := &CaptureHoldRequest{Amount: &amount, IdempotencyKey: "capture-1"}
+	first, err := env.service.CaptureHold(ctx, hold.ID.Hex(), req, "u1")
+	if err != nil {
+		t.Fatalf("CaptureHold: %v", err)
+	}
+
+	retry, err := env.service.CaptureHold(ctx, hold.ID.Hex(), req, "u1")
+	if err != nil {
+		t.Fatalf("CaptureHold retry: %v", err)
+	}
+	if len(retry) != 1 || retry[0].ID != first[0].ID {
+		t.Errorf("retried capture returned %v, want the original entry %s", retry, first[0].ID.Hex())
+	}
+
+	// A new capture of a settled hold is refused
+	if _, err := env.service.CaptureHold(ctx, hold.ID.Hex(), &CaptureHoldRequest{}, "u1"); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("second CaptureHold error = %v, want ErrHoldNotActive", err)
+	}
+
+	if got := env.balance(t, "u1", "store"); got != 600 {
+		t.Errorf("store balance = %s, want 6.00", got)
+	}
+	if got := env.held(t, "u1", "store"); got != 0 {
+		t.Errorf("held balance = %s, want 0.00", got)
+	}
+}
+
+func TestConcurrentCaptureAndReleaseSettleOnce(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	hold, err := env.service.CreateHold(ctx, &CreateHoldRequest{WalletType: "store", Amount: 500}, "u1")
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	captured := make(chan error, attempts)
+	released := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.CaptureHold(ctx, hold.ID.Hex(), &CaptureHoldRequest{}, "u1")
+			captured <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := env.service.ReleaseHold(ctx, hold.ID.Hex(), "u1")
+			released <- err
+		}()
+	}
+	wg.Wait()
+	close(captured)
+	close(released)
+
+	settled := func(errs chan error) int {
+		n := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				n++
+			case !errors.Is(err, ErrHoldNotActive):
+				t.Errorf("settle error = %v, want nil or ErrHoldNotActive", err)
+			}
+		}
+		return n
+	}
+	captures, releases := settled(captured), settled(released)
+
+	if captures+releases != 1 {
+		t.Fatalf("hold settled %d times (%d captures, %d releases), want once", captures+releases, captures, releases)
+	}
+
+	want := money.Amount(1000)
+	if captures == 1 {
+		want = 500
+	}
+	if got := env.balance(t, "u1", "store"); got != want {
+		t.Errorf("store balance = %s, want %s", got, want)
+	}
+	if got := env.held(t, "u1", "store"); got != 0 {
+		t.Errorf("held balance = %s, want 0.00", got)
+	}
+}
+
+func TestExpireHoldsReleasesOnceUnderConcurrency(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	hold, err := env.service.CreateHold(ctx, &CreateHoldRequest{WalletType: "store", Amount: 500}, "u1")
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	if _, err := env.db.Collection("holds").UpdateOne(ctx, bson.M{"_id": hold.ID},
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(-time.Minute)}}); err != nil {
+		t.Fatalf("backdate hold: %v", err)
+	}
+
+	// An expired hold can no longer be captured
+	if _, err := env.service.CaptureHold(ctx, hold.ID.Hex(), &CaptureHoldRequest{}, "u1"); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("CaptureHold of expired hold error = %v, want ErrHoldNotActive", err)
+	}
+
+	const runs = 5
+	var wg sync.WaitGroup
+	counts := make(chan int, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := env.service.ExpireHolds(ctx)
+			if err != nil {
+				t.Errorf("ExpireHolds: %v", err)
+			}
+			counts <- n
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	total := 0
+	for n := range counts {
+		total += n
+	}
+	if total != 1 {
+		t.Errorf("holds expired = %d, want 1", total)
+	}
+	if got := env.held(t, "u1", "store"); got != 0 {
+		t.Errorf("held balance = %s, want 0.00", got)
+	}
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("store balance = %s, want 10.00", got)
+	}
+}
@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Operation names that scope idempotency keys, so the same key sent to two
+// different endpoints does not collide.
+const (
+	operationAddBalance       = "add_balance"
+	operationDeductBalance    = "deduct_balance"
+	operationRefund           = "refund"
+	operationCaptureHold      = "capture_hold"
+	operationTransfer         = "transfer"
+	operationInternalTransfer = "internal_transfer"
+)
+
+// runIdempotent runs fn as one unit of work. fn returns the ledger entries it
+// wrote, which share one OperationID. When key is set, that operation is
+// remembered under (userID, operation, key) in the same unit of work, and a
+// replay of the key returns its entries instead of running fn again.
+func (s *walletService) runIdempotent(ctx context.Context, userID string, operation string, key string, req interface{}, fn func(ctx context.Context) ([]*Transactions, error)) ([]*Transactions, error) {
+
+	var result []*Transactions
+
+	if key == "" {
+		err := s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			result, err = fn(ctx)
+			return err
+		})
+		return result, err
+	}
+
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed, err := s.replay(ctx, userID, operation, key, hash)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return replayed, err
+	}
+
+	err = s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		if err != nil {
+			return err
+		}
+		if len(result) == 0 {
+			return fmt.Errorf("operation wrote no transactions")
+		}
+
+		return s.walletRepo.CreateIdempotencyRecord(ctx, &IdempotencyRecord{
+			ID:          primitive.NewObjectID(),
+			UserID:      userID,
+			Operation:   operation,
+			Key:         key,
+			RequestHash: hash,
+			OperationID: result[0].OperationID,
+			CreatedAt:   time.Now(),
+		})
+	})
+
+	// A concurrent request with the same key committed first; its result
+	// is the one to return.
+	if mongo.IsDuplicateKeyError(err) {
+		return s.replay(ctx, userID, operation, key, hash)
+	}
+
+	return result, err
+}
+
+func (s *walletService) replay(ctx context.Context, userID string, operation string, key string, hash string) ([]*Transactions, error) {
+
+	record, err := s.walletRepo.GetIdempotencyRecord(ctx, userID, operation, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	// Errors below must not wrap mongo.ErrNoDocuments, which callers take
+	// to mean the key was never used.
+
+	// Records written before operations had IDs name the one transaction
+	// they produced instead.
+	if record.OperationID.IsZero() && record.TransactionID != nil {
+		transaction, err := s.walletRepo.GetTransactionByID(ctx, *record.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay idempotency key %s: %v", key, err)
+		}
+		return []*Transactions{transaction}, nil
+	}
+
+	transactions, err := s.walletRepo.GetTransactionsByOperationID(ctx, record.OperationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay idempotency key %s: %v", key, err)
+	}
+
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("idempotency key %s has no recorded result to replay", key)
+	}
+
+	return transactions, nil
+}
+
+func requestHash(req interface{}) (string, error) {
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReplayOfRecordWrittenBeforeOperationIDs(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+
+	req := &AddBalanceRequest{UserID: "u1", WalletType: "store", Balance: 500, Currency: testCurrency, IdempotencyKey: "legacy-key"}
+	hash, err := requestHash(req)
+	if err != nil {
+		t.Fatalf("requestHash: %v", err)
+	}
+
+	// A deposit and its key as stored before operation IDs existed: the
+	// record names the transaction directly.
+	transactionID := primitive.NewObjectID()
+	if _, err := env.db.Collection("transactions").InsertOne(ctx, bson.M{
+		"_id": transactionID, "user_id": "u1", "type": "deposit", "wallet_type": "store",
+		"amount": int64(500), "created_at": time.Now(),
+	}); err != nil {
+		t.Fatalf("insert legacy transaction: %v", err)
+	}
+	if _, err := env.db.Collection("idempotency_keys").InsertOne(ctx, bson.M{
+		"_id": primitive.NewObjectID(), "user_id": "admin", "operation": operationAddBalance, "key": "legacy-key",
+		"request_hash": hash, "transaction_id": transactionID, "created_at": time.Now(),
+	}); err != nil {
+		t.Fatalf("insert legacy idempotency record: %v", err)
+	}
+
+	transaction, err := env.service.AddBalance(ctx, req, "admin")
+	if err != nil {
+		t.Fatalf("AddBalance replay: %v", err)
+	}
+	if transaction.ID != transactionID {
+		t.Errorf("replayed transaction = %s, want %s", transaction.ID.Hex(), transactionID.Hex())
+	}
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("store balance = %s, want 10.00: the replay must not credit again", got)
+	}
+}
+
+func TestReplayWithNoRecordedResultFails(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+
+	req := &AddBalanceRequest{UserID: "u1", WalletType: "store", Balance: 500, Currency: testCurrency, IdempotencyKey: "orphan-key"}
+	hash, err := requestHash(req)
+	if err != nil {
+		t.Fatalf("requestHash: %v", err)
+	}
+
+	if _, err := env.db.Collection("idempotency_keys").InsertOne(ctx, bson.M{
+		"_id": primitive.NewObjectID(), "user_id": "admin", "operation": operationAddBalance, "key": "orphan-key",
+		"request_hash": hash, "created_at": time.Now(),
+	}); err != nil {
+		t.Fatalf("insert idempotency record: %v", err)
+	}
+
+	if _, err := env.service.AddBalance(ctx, req, "admin"); err == nil {
+		t.Fatal("AddBalance replay of a key with no result succeeded, want error")
+	}
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("store balance = %s, want 10.00", got)
+	}
+}
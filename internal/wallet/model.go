@@ -2,30 +2,146 @@ package wallet
 
 import (
 	"time"
+	"wallet-service/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Wallet is the balance projection of a user's wallet account in the ledger:
+// Balance always equals the credit balance of ledger.WalletAccount and is
+// updated in the same Mongo transaction as the journal entry that moves it.
+//
+// HeldBalance is the part of Balance reserved by active holds; only
+// Balance - HeldBalance can be spent or held again.
+//
+// Balances are denominated in Currency, taken from the wallet type when the
+// wallet is created.
+//
+// LedgerOpenedAt is set once the wallet's journal account has its opening balance.
 type Wallet struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID     string             `bson:"user_id" json:"user_id"`
-	Balance    float64            `bson:"balance" json:"balance"`
-	WalletType string             `bson:"wallet_type" json:"wallet_type"`
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Balance     money.Amount       `bson:"balance" json:"balance"`
+	HeldBalance money.Amount       `bson:"held_balance" json:"held_balance"`
+	WalletType  string             `bson:"wallet_type" json:"wallet_type"`
+	Currency    string             `bson:"currency" json:"currency"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+
+	LedgerOpenedAt *time.Time `bson:"ledger_opened_at,omitempty" json:"-"`
 }
 
+// Transactions is one ledger entry: the movement of a single wallet. An
+// operation touching several wallets (a purchase split across store and
+// service) writes one entry per wallet, all sharing OperationID.
+//
+// Amount is signed, negative for debits, and BalanceBefore + Amount ==
+// BalanceAfter. Entries written before per-wallet recording have no
+// WalletType, an unsigned Amount and no balances.
+//
+// Amount is in WalletCurrency. For deposits, Money is what was paid in, in
+// Currency, before conversion with the rate version ExchangeRateID.
 type Transactions struct {
-	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID    string              `bson:"user_id" json:"user_id"`
-	Type      string              `bson:"type" json:"type"`
-	Amount    float64             `bson:"amount" json:"amount"`
-	Money     *float64            `bson:"money" json:"money"`
-	Currency  *string             `bson:"currency" json:"currency"`
-	OrderID   *primitive.ObjectID `bson:"order_id" json:"order_id"`
-	AdminID   *string             `bson:"admin_id" json:"admin_id"`
-	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time           `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	OperationID    primitive.ObjectID `bson:"operation_id,omitempty" json:"operation_id,omitempty"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	Type           string             `bson:"type" json:"type"`
+	WalletType     string             `bson:"wallet_type,omitempty" json:"wallet_type,omitempty"`
+	Amount         money.Amount       `bson:"amount" json:"amount"`
+	BalanceBefore  *money.Amount      `bson:"balance_before,omitempty" json:"balance_before,omitempty"`
+	BalanceAfter   *money.Amount      `bson:"balance_after,omitempty" json:"balance_after,omitempty"`
+	Money          *money.Amount      `bson:"money" json:"money"`
+	Currency       *string            `bson:"currency" json:"currency"`
+	WalletCurrency string             `bson:"wallet_currency,omitempty" json:"wallet_currency,omitempty"`
+	// ExchangeRateID is the rate version a deposit was converted with.
+	ExchangeRateID *primitive.ObjectID `bson:"exchange_rate_id,omitempty" json:"exchange_rate_id,omitempty"`
+	OrderID        *primitive.ObjectID `bson:"order_id" json:"order_id"`
+	AdminID        *string             `bson:"admin_id" json:"admin_id"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time           `bson:"updated_at" json:"updated_at"`
+
+	IdempotencyKey *string `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"`
+
+	// RefundedAmount is how much of a purchase entry has been given back.
+	RefundedAmount money.Amount `bson:"refunded_amount,omitempty" json:"refunded_amount,omitempty"`
+	// ReversalOf links a refund entry to the purchase entry it reverses.
+	ReversalOf *primitive.ObjectID `bson:"reversal_of,omitempty" json:"reversal_of,omitempty"`
+	// HoldID links a purchase entry to the hold it captured.
+	HoldID *primitive.ObjectID `bson:"hold_id,omitempty" json:"hold_id,omitempty"`
+	// CounterpartyUserID is the other side of a transfer.
+	CounterpartyUserID string `bson:"counterparty_user_id,omitempty" json:"counterparty_user_id,omitempty"`
+	Note               string `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// Hold statuses. Only an active hold reserves funds.
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+// Hold reserves Amount of a wallet until it is captured, released or
+// expires. Capturing debits the captured part and releases the rest.
+type Hold struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID         string              `bson:"user_id" json:"user_id"`
+	WalletType     string              `bson:"wallet_type" json:"wallet_type"`
+	Amount         money.Amount        `bson:"amount" json:"amount"`
+	CapturedAmount money.Amount        `bson:"captured_amount" json:"captured_amount"`
+	Status         string              `bson:"status" json:"status"`
+	OrderID        *primitive.ObjectID `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	ExpiresAt      time.Time           `bson:"expires_at" json:"expires_at"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// TransferTotal is what a user has sent by transfer on one UTC day.
+type TransferTotal struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Day       string             `bson:"day" json:"day"`
+	Total     money.Amount       `bson:"total" json:"total"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// IdempotencyRecord remembers which operation a caller's Idempotency-Key
+// produced, so a retried request returns that result instead of running
+// again. Records expire through a TTL index on CreatedAt.
+type IdempotencyRecord struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Operation   string             `bson:"operation" json:"operation"`
+	Key         string             `bson:"key" json:"key"`
+	RequestHash string             `bson:"request_hash" json:"request_hash"`
+	OperationID primitive.ObjectID `bson:"operation_id" json:"operation_id"`
+	// TransactionID is only set on records written before operations had
+	// IDs, which name the single transaction they produced.
+	TransactionID *primitive.ObjectID `bson:"transaction_id,omitempty" json:"-"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// OrderCharge marks an order as paid. Its _id is the order ID, so a second
+// charge for the same order fails on the primary key.
+type OrderCharge struct {
+	OrderID     primitive.ObjectID `bson:"_id" json:"order_id"`
+	OperationID primitive.ObjectID `bson:"operation_id" json:"operation_id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Items       []OrderItem        `bson:"items,omitempty" json:"items,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ReconciliationAudit records a balance a reconciliation run overwrote, so
+// every repair can be traced and undone.
+type ReconciliationAudit struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RunID         primitive.ObjectID `bson:"run_id" json:"run_id"`
+	WalletID      primitive.ObjectID `bson:"wallet_id" json:"wallet_id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	WalletType    string             `bson:"wallet_type" json:"wallet_type"`
+	BalanceBefore money.Amount       `bson:"balance_before" json:"balance_before"`
+	BalanceAfter  money.Amount       `bson:"balance_after" json:"balance_after"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
 }
 
 type ServiceUsageLog struct {
@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"wallet-service/internal/exchange"
+	"wallet-service/internal/ledger"
+	"wallet-service/internal/user"
+	"wallet-service/internal/wallettype"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testCurrency is the currency of the wallet types seeded for tests.
+const testCurrency = "POINT"
+
+// testUsers knows every user, so existence checks pass without the main
+// service.
+type testUsers struct{}
+
+func (testUsers) GetUserInfor(ctx context.Context, userID string) (*user.UserInfor, error) {
+	return &user.UserInfor{UserID: userID}, nil
+}
+
+// testEnv is a wallet service wired to a throwaway database.
+type testEnv struct {
+	db      *mongo.Database
+	repo    WalletRepository
+	service WalletService
+}
+
+// newTestEnv connects to the replica set named by MONGO_TEST_URI, which
+// transactions need, and gives the test its own database, dropped when the
+// test ends. Tests are skipped when MONGO_TEST_URI is not set.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return newTestEnvWithSettings(t, Settings{})
+}
+
+func newTestEnvWithSettings(t *testing.T, settings Settings) *testEnv {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set; skipping test that needs a Mongo replica set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	db := client.Database(fmt.Sprintf("wallet_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	walletTypeRepo := wallettype.NewWalletTypeRepository(db.Collection("wallet_types"))
+	if err := walletTypeRepo.SeedWalletTypes(ctx, wallettype.Defaults(testCurrency)); err != nil {
+		t.Fatalf("seed wallet types: %v", err)
+	}
+
+	repo := NewWalletRepository(db.Collection("wallets"), db.Collection("transactions"), db.Collection("idempotency_keys"),
+		db.Collection("reconciliation_audits"), db.Collection("order_charges"), db.Collection("holds"), db.Collection("transfer_totals"))
+	if err := repo.EnsureIndexes(ctx, time.Hour); err != nil {
+		t.Fatalf("wallet indexes: %v", err)
+	}
+
+	// Transactions cannot create collections on older servers, so create
+	// them up front.
+	for _, name := range []string{"wallets", "transactions", "idempotency_keys", "order_charges", "holds", "journal_entries", "transfer_totals"} {
+		_ = db.CreateCollection(ctx, name)
+	}
+
+	service := NewWalletService(repo,
+		exchange.NewExchangeService(exchange.NewExchangeRepository(db.Collection("exchange_rates"))),
+		wallettype.NewWalletTypeService(walletTypeRepo),
+		testUsers{},
+		ledger.NewLedgerService(ledger.NewLedgerRepository(db.Collection("journal_entries"))),
+		settings)
+
+	return &testEnv{db: db, repo: repo, service: service}
+}
+
+// fund credits a user's wallet in the wallet currency.
+func (e *testEnv) fund(t *testing.T, userID string, walletType string, amount money.Amount) {
+	t.Helper()
+
+	_, err := e.service.AddBalance(context.Background(), &AddBalanceRequest{
+		UserID:     userID,
+		WalletType: walletType,
+		Balance:    amount,
+		Currency:   testCurrency,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("fund %s %s wallet: %v", userID, walletType, err)
+	}
+}
+
+func (e *testEnv) balance(t *testing.T, userID string, walletType string) money.Amount {
+	t.Helper()
+
+	wallet, err := e.repo.GetBalanceUser(context.Background(), userID, walletType)
+	if err != nil {
+		t.Fatalf("get %s %s wallet: %v", userID, walletType, err)
+	}
+	return wallet.Balance
+}
+
+func (e *testEnv) count(t *testing.T, collection string, filter bson.M) int64 {
+	t.Helper()
+
+	n, err := e.db.Collection(collection).CountDocuments(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("count %s: %v", collection, err)
+	}
+	return n
+}
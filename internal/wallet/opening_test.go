@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+	"wallet-service/internal/ledger"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMigrateOpeningBalancesPostsPreLedgerBalances(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// A wallet from before the ledger, holding 7.00 nothing in the journal
+	// accounts for, then topped up by 3.00 through the ledger.
+	if _, err := env.db.Collection("wallets").InsertOne(ctx, bson.M{
+		"_id": primitive.NewObjectID(), "user_id": "u1", "wallet_type": "store", "currency": testCurrency,
+		"balance": money.Amount(700), "held_balance": money.Amount(0), "created_at": time.Now(), "updated_at": time.Now(),
+	}); err != nil {
+		t.Fatalf("insert legacy wallet: %v", err)
+	}
+	env.fund(t, "u1", "store", 300)
+
+	ledgerService := ledger.NewLedgerService(ledger.NewLedgerRepository(env.db.Collection("journal_entries")))
+	account := ledger.WalletAccount("u1", "store")
+
+	for run := 1; run <= 2; run++ {
+		if err := env.service.MigrateOpeningBalances(ctx); err != nil {
+			t.Fatalf("MigrateOpeningBalances run %d: %v", run, err)
+		}
+
+		balance, err := ledgerService.GetAccountBalance(ctx, account, testCurrency)
+		if err != nil {
+			t.Fatalf("GetAccountBalance: %v", err)
+		}
+		if balance.Balance != env.balance(t, "u1", "store") {
+			t.Errorf("run %d: account balance = %s, want wallet balance %s", run, balance.Balance, env.balance(t, "u1", "store"))
+		}
+		if n := env.count(t, "journal_entries", bson.M{"type": "opening_balance"}); n != 1 {
+			t.Errorf("run %d: opening entries = %d, want 1", run, n)
+		}
+	}
+
+	trial, err := ledgerService.GetTrialBalance(ctx)
+	if err != nil {
+		t.Fatalf("GetTrialBalance: %v", err)
+	}
+	if !trial.Balanced {
+		t.Error("trial balance is not balanced after opening balances")
+	}
+}
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 123e6, time.UTC)
+	id := primitive.NewObjectID()
+
+	gotAt, gotID, err := decodeCursor(encodeCursor(createdAt, id))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !gotAt.Equal(createdAt) {
+		t.Errorf("created_at = %s, want %s", gotAt, createdAt)
+	}
+	if gotID != id {
+		t.Errorf("id = %s, want %s", gotID.Hex(), id.Hex())
+	}
+}
+
+func TestDecodeCursorRejectsMalformed(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not base64!"},
+		{"missing separator", encode("1700000000000" + primitive.NewObjectID().Hex())},
+		{"non-numeric millis", encode("yesterday:" + primitive.NewObjectID().Hex())},
+		{"bad object id", encode("1700000000000:not-an-object-id")},
+		{"empty", encode("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) succeeded, want error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestGetTransactionsPagesEntriesWithIdenticalTimestamps(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// Every entry shares one timestamp, so only _id orders them.
+	createdAt := time.Now().Truncate(time.Millisecond)
+	const entries = 10
+	want := make(map[primitive.ObjectID]bool)
+	for i := 0; i < entries; i++ {
+		id := primitive.NewObjectID()
+		want[id] = true
+		if _, err := env.db.Collection("transactions").InsertOne(ctx, bson.M{
+			"_id": id, "user_id": "u1", "type": "deposit", "wallet_type": "store", "amount": int64(100), "created_at": createdAt,
+		}); err != nil {
+			t.Fatalf("insert transaction: %v", err)
+		}
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > entries {
+			t.Fatal("pagination did not terminate")
+		}
+
+		page, err := env.service.GetTransactions(ctx, "u1", &TransactionFilter{Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("GetTransactions: %v", err)
+		}
+		for _, transaction := range page.Transactions {
+			if seen[transaction.ID] {
+				t.Errorf("transaction %s returned on two pages", transaction.ID.Hex())
+			}
+			seen[transaction.ID] = true
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	for id := range want {
+		if !seen[id] {
+			t.Errorf("transaction %s was skipped", id.Hex())
+		}
+	}
+}
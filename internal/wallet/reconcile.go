@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wallet-service/internal/ledger"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReconcileService interface {
+	Reconcile(ctx context.Context, repair bool) (*ReconciliationReport, error)
+}
+
+type reconcileService struct {
+	walletRepo    WalletRepository
+	ledgerService ledger.LedgerService
+}
+
+func NewReconcileService(walletRepo WalletRepository, ledgerService ledger.LedgerService) ReconcileService {
+	return &reconcileService{
+		walletRepo:    walletRepo,
+		ledgerService: ledgerService,
+	}
+}
+
+// Reconcile compares every opened wallet with its journal account and, with
+// repair set, resets mismatched wallets to the journal value.
+func (s *reconcileService) Reconcile(ctx context.Context, repair bool) (*ReconciliationReport, error) {
+
+	runID := primitive.NewObjectID()
+
+	report := &ReconciliationReport{
+		RunID:      runID.Hex(),
+		StartedAt:  time.Now(),
+		Repair:     repair,
+		Mismatches: []*BalanceMismatch{},
+	}
+
+	wallets, err := s.walletRepo.GetAllWallets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+
+	for _, wallet := range wallets {
+		if wallet.LedgerOpenedAt == nil {
+			report.Unopened++
+			continue
+		}
+
+		mismatch, err := s.reconcileWallet(ctx, runID, wallet.ID, repair)
+		report.WalletsChecked++
+
+		// One failing wallet is reported and does not stop the run
+		if err != nil {
+			report.Mismatches = append(report.Mismatches, &BalanceMismatch{
+				WalletID:   wallet.ID.Hex(),
+				UserID:     wallet.UserID,
+				WalletType: wallet.WalletType,
+				Error:      err.Error(),
+			})
+			continue
+		}
+
+		if mismatch == nil {
+			continue
+		}
+
+		report.Mismatches = append(report.Mismatches, mismatch)
+		if mismatch.Repaired {
+			report.Repaired++
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	return report, nil
+}
+
+// reconcileWallet never repairs a wallet whose journal account has no postings.
+func (s *reconcileService) reconcileWallet(ctx context.Context, runID primitive.ObjectID, walletID primitive.ObjectID, repair bool) (*BalanceMismatch, error) {
+
+	var mismatch *BalanceMismatch
+
+	err := s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+
+		mismatch = nil
+
+		wallet, err := s.walletRepo.GetWalletByID(ctx, walletID)
+		if err != nil {
+			return err
+		}
+
+		account, err := s.ledgerService.GetAccountBalance(ctx, ledger.WalletAccount(wallet.UserID, wallet.WalletType), wallet.Currency)
+		if err != nil {
+			return err
+		}
+
+		if wallet.Balance == account.Balance {
+			return nil
+		}
+
+		noEntries := account.Debit == 0 && account.Credit == 0
+		mismatch = &BalanceMismatch{
+			WalletID:   wallet.ID.Hex(),
+			UserID:     wallet.UserID,
+			WalletType: wallet.WalletType,
+			Balance:    wallet.Balance,
+			Expected:   account.Balance,
+			Difference: wallet.Balance - account.Balance,
+			NoEntries:  noEntries,
+		}
+
+		if !repair || noEntries {
+			return nil
+		}
+
+		if err := s.walletRepo.SetBalance(ctx, wallet.ID, wallet.Balance, account.Balance); err != nil {
+			return err
+		}
+
+		err = s.walletRepo.CreateReconciliationAudit(ctx, &ReconciliationAudit{
+			ID:            primitive.NewObjectID(),
+			RunID:         runID,
+			WalletID:      wallet.ID,
+			UserID:        wallet.UserID,
+			WalletType:    wallet.WalletType,
+			BalanceBefore: wallet.Balance,
+			BalanceAfter:  account.Balance,
+			CreatedAt:     time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		mismatch.Repaired = true
+		return nil
+	})
+
+	return mismatch, err
+}
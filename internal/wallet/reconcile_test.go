@@ -0,0 +1,75 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+	"wallet-service/internal/ledger"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReconcileRepairsAgainstTheJournal(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	ledgerService := ledger.NewLedgerService(ledger.NewLedgerRepository(env.db.Collection("journal_entries")))
+	reconcileService := NewReconcileService(env.repo, ledgerService)
+
+	// u1 drifted from the journal; u2 was opened but nothing was ever
+	// posted; u3 has not been opened in the ledger yet.
+	env.fund(t, "u1", "store", 1000)
+	if _, err := env.db.Collection("wallets").UpdateOne(ctx, bson.M{"user_id": "u1", "wallet_type": "store"},
+		bson.M{"$set": bson.M{"balance": money.Amount(1200)}}); err != nil {
+		t.Fatalf("drift u1: %v", err)
+	}
+	now := time.Now()
+	for _, doc := range []bson.M{
+		{"user_id": "u2", "ledger_opened_at": now},
+		{"user_id": "u3"},
+	} {
+		doc["_id"] = primitive.NewObjectID()
+		doc["wallet_type"] = "store"
+		doc["currency"] = testCurrency
+		doc["balance"] = money.Amount(500)
+		doc["held_balance"] = money.Amount(0)
+		if _, err := env.db.Collection("wallets").InsertOne(ctx, doc); err != nil {
+			t.Fatalf("insert wallet: %v", err)
+		}
+	}
+
+	report, err := reconcileService.Reconcile(ctx, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	if report.Unopened != 1 {
+		t.Errorf("unopened = %d, want 1", report.Unopened)
+	}
+	if report.Repaired != 1 {
+		t.Errorf("repaired = %d, want 1", report.Repaired)
+	}
+
+	mismatches := map[string]*BalanceMismatch{}
+	for _, mismatch := range report.Mismatches {
+		mismatches[mismatch.UserID] = mismatch
+	}
+	if m := mismatches["u1"]; m == nil || !m.Repaired || m.Expected != 1000 {
+		t.Errorf("u1 mismatch = %+v, want repaired to 10.00", m)
+	}
+	if m := mismatches["u2"]; m == nil || m.Repaired || !m.NoEntries {
+		t.Errorf("u2 mismatch = %+v, want unrepaired with no entries", m)
+	}
+	if _, ok := mismatches["u3"]; ok {
+		t.Error("unopened wallet u3 was reported as a mismatch")
+	}
+
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("u1 balance = %s, want 10.00", got)
+	}
+	if got := env.balance(t, "u2", "store"); got != 500 {
+		t.Errorf("u2 balance = %s, want 5.00 untouched", got)
+	}
+}
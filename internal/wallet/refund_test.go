@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRefundPartialThenRest(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	deduction, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 600}, "u1")
+	if err != nil {
+		t.Fatalf("DeductBalance: %v", err)
+	}
+	purchaseID := deduction.Transactions[0].ID.Hex()
+
+	if _, err := env.service.Refund(ctx, &RefundRequest{TransactionID: purchaseID, Legs: []RefundLeg{{WalletType: "store", Amount: 200}}}, "admin"); err != nil {
+		t.Fatalf("partial Refund: %v", err)
+	}
+	if got := env.balance(t, "u1", "store"); got != 600 {
+		t.Errorf("store balance = %s, want 6.00 after partial refund", got)
+	}
+
+	// Without legs, whatever is left is refunded
+	if _, err := env.service.Refund(ctx, &RefundRequest{TransactionID: purchaseID}, "admin"); err != nil {
+		t.Fatalf("Refund of the rest: %v", err)
+	}
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("store balance = %s, want 10.00 after full refund", got)
+	}
+
+	if _, err := env.service.Refund(ctx, &RefundRequest{TransactionID: purchaseID}, "admin"); err == nil {
+		t.Error("Refund of a fully refunded purchase succeeded, want error")
+	}
+}
+
+func TestRefundExceedingChargeFails(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	deduction, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 300}, "u1")
+	if err != nil {
+		t.Fatalf("DeductBalance: %v", err)
+	}
+
+	_, err = env.service.Refund(ctx, &RefundRequest{
+		TransactionID: deduction.Transactions[0].ID.Hex(),
+		Legs:          []RefundLeg{{WalletType: "store", Amount: 301}},
+	}, "admin")
+	if !errors.Is(err, ErrRefundExceedsCharge) {
+		t.Fatalf("Refund error = %v, want ErrRefundExceedsCharge", err)
+	}
+	if got := env.balance(t, "u1", "store"); got != 700 {
+		t.Errorf("store balance = %s, want 7.00", got)
+	}
+}
+
+func TestConcurrentRefundsNeverExceedCharge(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	deduction, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 500}, "u1")
+	if err != nil {
+		t.Fatalf("DeductBalance: %v", err)
+	}
+	purchaseID := deduction.Transactions[0].ID.Hex()
+
+	// Five refunds of 1.00 fit the 5.00 charge; twenty race for it.
+	const attempts = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.Refund(ctx, &RefundRequest{TransactionID: purchaseID, Legs: []RefundLeg{{WalletType: "store", Amount: 100}}}, "admin")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrRefundExceedsCharge):
+			t.Errorf("Refund error = %v, want nil or ErrRefundExceedsCharge", err)
+		}
+	}
+
+	if succeeded != 5 {
+		t.Errorf("successful refunds = %d, want 5", succeeded)
+	}
+	if got := env.balance(t, "u1", "store"); got != 1000 {
+		t.Errorf("store balance = %s, want 10.00", got)
+	}
+}
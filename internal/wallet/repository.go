@@ -2,34 +2,81 @@ package wallet
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"wallet-service/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type WalletRepository interface{
 	CreateWallet(ctx context.Context, wallet *Wallet) error
 	GetWalletByUserID(ctx context.Context, userID string) ([]*Wallet, error)
-	AddBalance(ctx context.Context, userID string, walletType string, balance float64) error
+	AddBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error)
 	CreateTransaction(ctx context.Context, transaction *Transactions) error
 	GetBalanceUser(ctx context.Context, userID string, walletType string) (*Wallet, error)
-	DeductBalance(ctx context.Context, price float64, walletType string, userID string) error
+	DeductBalance(ctx context.Context, price money.Amount, walletType string, userID string, allowNegative bool) (*Wallet, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	MigrateMoneyFields(ctx context.Context) error
+	GetTransactionByID(ctx context.Context, id primitive.ObjectID) (*Transactions, error)
+	GetTransactions(ctx context.Context, filter bson.M, limit int64) ([]*Transactions, error)
+	GetTransactionsByOperationID(ctx context.Context, operationID primitive.ObjectID) ([]*Transactions, error)
+	CreateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error
+	GetIdempotencyRecord(ctx context.Context, userID string, operation string, key string) (*IdempotencyRecord, error)
+	EnsureIndexes(ctx context.Context, idempotencyTTL time.Duration) error
+	GetAllWallets(ctx context.Context) ([]*Wallet, error)
+	GetWalletByID(ctx context.Context, id primitive.ObjectID) (*Wallet, error)
+	SetBalance(ctx context.Context, id primitive.ObjectID, from money.Amount, to money.Amount) error
+	CreateReconciliationAudit(ctx context.Context, audit *ReconciliationAudit) error
+	AddRefundedAmount(ctx context.Context, id primitive.ObjectID, amount money.Amount) error
+	CreateOrderCharge(ctx context.Context, charge *OrderCharge) error
+	GetOrderCharge(ctx context.Context, orderID primitive.ObjectID) (*OrderCharge, error)
+	HoldBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error)
+	CaptureHeldBalance(ctx context.Context, userID string, walletType string, capture money.Amount, release money.Amount) (*Wallet, error)
+	ReleaseHeldBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error)
+	CreateHold(ctx context.Context, hold *Hold) error
+	GetHold(ctx context.Context, id primitive.ObjectID) (*Hold, error)
+	CloseHold(ctx context.Context, id primitive.ObjectID, status string, capturedAmount money.Amount) error
+	GetExpiredHolds(ctx context.Context, now time.Time) ([]*Hold, error)
+	AddTransferTotal(ctx context.Context, userID string, day time.Time, amount money.Amount, limit money.Amount) error
+	SetMissingCurrency(ctx context.Context, walletType string, currency string) error
+	GetUnopenedWallets(ctx context.Context) ([]*Wallet, error)
+	SetLedgerOpened(ctx context.Context, id primitive.ObjectID) error
 }
 
 type walletRepository struct{
 	collection *mongo.Collection
 	collectionTransaction *mongo.Collection	
+	collectionIdempotency *mongo.Collection
+	collectionAudit       *mongo.Collection
+	collectionOrder       *mongo.Collection
+	collectionHold        *mongo.Collection
+	collectionTransferTotal *mongo.Collection
 }
 
-func NewWalletRepository(collection *mongo.Collection, collectionTransaction *mongo.Collection) WalletRepository {
+func NewWalletRepository(collection *mongo.Collection, collectionTransaction *mongo.Collection, collectionIdempotency *mongo.Collection, collectionAudit *mongo.Collection, collectionOrder *mongo.Collection, collectionHold *mongo.Collection, collectionTransferTotal *mongo.Collection) WalletRepository {
 	return &walletRepository{
 		collection: collection,
 		collectionTransaction: collectionTransaction,
+		collectionIdempotency: collectionIdempotency,
+		collectionAudit:       collectionAudit,
+		collectionOrder:       collectionOrder,
+		collectionHold:        collectionHold,
+		collectionTransferTotal: collectionTransferTotal,
 	}
 }
 
+// CreateWallet inserts a wallet. A wallet of the same user and type created
+// concurrently by another request is not an error.
 func (r *walletRepository) CreateWallet(ctx context.Context, wallet *Wallet) error {
 	_, err := r.collection.InsertOne(ctx, wallet)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
 	return err
 }
 
@@ -50,22 +97,35 @@ func (r *walletRepository) GetWalletByUserID(ctx context.Context, userID string)
 
 }
 
-func (r *walletRepository) AddBalance(ctx context.Context, userID string, walletType string, balance float64) error {
-	
+func (r *walletRepository) AddBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error) {
+
+	// Credits are applied with $inc on the server so overlapping top-ups
+	// never overwrite each other. The wallet is returned as it is after the
+	// increment.
 	filter := bson.M{
-		"user_id": userID,
+		"user_id":     userID,
 		"wallet_type": walletType,
 	}
 
 	update := bson.M{
+		"$inc": bson.M{
+			"balance": amount,
+		},
 		"$set": bson.M{
-			"balance": balance,
+			"updated_at": time.Now(),
 		},
 	}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	var wallet Wallet
 
-	return err
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
 
 }
 
@@ -88,19 +148,573 @@ func (r *walletRepository) GetBalanceUser(ctx context.Context, userID string, wa
 	return result, err
 }
 
-func (r *walletRepository) DeductBalance(ctx context.Context, price float64, walletType string, userID string) error {
-	
+func (r *walletRepository) DeductBalance(ctx context.Context, price money.Amount, walletType string, userID string, allowNegative bool) (*Wallet, error) {
+
+	// The balance guard and the decrement happen in a single update, so
+	// concurrent debits can never take the wallet below zero or spend funds
+	// reserved by a hold. Wallet types allowed to go negative skip the
+	// guard. The wallet is returned as it is after the decrement.
 	filter := bson.M{
-		"user_id": userID,
+		"user_id":     userID,
 		"wallet_type": walletType,
 	}
 
+	if !allowNegative {
+		filter["$expr"] = availableAtLeast(price)
+	}
+
 	update := bson.M{
 		"$inc": bson.M{
 			"balance": -price,
 		},
+		"$set": bson.M{
+			"updated_at": time.Now(),
+		},
+	}
+
+	var wallet Wallet
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if err == mongo.ErrNoDocuments {
+		count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID, "wallet_type": walletType})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, mongo.ErrNoDocuments
+		}
+		return nil, &InsufficientFundsError{UserID: userID, WalletType: walletType, Amount: price}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// WithTransaction runs fn inside a MongoDB multi-document transaction. Every
+// repository call made with the context handed to fn joins the same session,
+// so the writes either all commit or are all rolled back. Transactions need
+// MongoDB running as a replica set (a single-node replica set is enough).
+func (r *walletRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+
+	session, err := r.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
+
+// MigrateMoneyFields rewrites balances and transaction amounts that were
+// stored as float major units into int64 minor units. Documents already in
+// the new form are not matched, so it is safe to run on every start.
+func (r *walletRepository) MigrateMoneyFields(ctx context.Context) error {
+
+	if _, err := r.collection.UpdateMany(ctx, money.LegacyFilter("balance"), money.LegacyUpdate("balance", money.AmountScale)); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"amount", "money"} {
+		if _, err := r.collectionTransaction.UpdateMany(ctx, money.LegacyFilter(field), money.LegacyUpdate(field, money.AmountScale)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *walletRepository) GetTransactionByID(ctx context.Context, id primitive.ObjectID) (*Transactions, error) {
+
+	var transaction Transactions
+
+	err := r.collectionTransaction.FindOne(ctx, bson.M{"_id": id}).Decode(&transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+func (r *walletRepository) GetTransactions(ctx context.Context, filter bson.M, limit int64) ([]*Transactions, error) {
+
+	var transactions []*Transactions
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := r.collectionTransaction.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (r *walletRepository) GetTransactionsByOperationID(ctx context.Context, operationID primitive.ObjectID) ([]*Transactions, error) {
+
+	var transactions []*Transactions
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := r.collectionTransaction.Find(ctx, bson.M{"operation_id": operationID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (r *walletRepository) CreateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error {
+	_, err := r.collectionIdempotency.InsertOne(ctx, record)
+	return err
+}
+
+func (r *walletRepository) GetIdempotencyRecord(ctx context.Context, userID string, operation string, key string) (*IdempotencyRecord, error) {
+
+	var record IdempotencyRecord
+
+	filter := bson.M{
+		"user_id":   userID,
+		"operation": operation,
+		"key":       key,
+	}
+
+	err := r.collectionIdempotency.FindOne(ctx, filter).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// EnsureIndexes creates the indexes the wallet package relies on. The
+// idempotency TTL index decides how long a key can be replayed.
+func (r *walletRepository) EnsureIndexes(ctx context.Context, idempotencyTTL time.Duration) error {
+
+	// One wallet per user and type; wallets are created lazily from the
+	// catalog, so concurrent reads must not create a type twice.
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "wallet_type", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collectionIdempotency.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "operation", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(idempotencyTTL.Seconds())),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One running total per user and day, dropped once the day is over.
+	_, err = r.collectionTransferTotal.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "day", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collectionHold.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// History queries always pin user_id and page on (created_at, _id).
+	_, err = r.collectionTransaction.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "wallet_type", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "operation_id", Value: 1}}},
+		{Keys: bson.D{{Key: "reversal_of", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "admin_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	return err
+}
+
+func (r *walletRepository) GetAllWallets(ctx context.Context) ([]*Wallet, error) {
+
+	var wallets []*Wallet
+
+	opts := options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}, {Key: "wallet_type", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+func (r *walletRepository) GetWalletByID(ctx context.Context, id primitive.ObjectID) (*Wallet, error) {
+
+	var wallet Wallet
+
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// SetBalance overwrites a wallet balance only if it still equals from, so a
+// repair never clobbers a movement that happened after the check.
+func (r *walletRepository) SetBalance(ctx context.Context, id primitive.ObjectID, from money.Amount, to money.Amount) error {
+
+	filter := bson.M{
+		"_id":     id,
+		"balance": from,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"balance":    to,
+			"updated_at": time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("wallet %s changed during repair", id.Hex())
+	}
+
+	return nil
+}
+
+func (r *walletRepository) CreateReconciliationAudit(ctx context.Context, audit *ReconciliationAudit) error {
+	_, err := r.collectionAudit.InsertOne(ctx, audit)
+	return err
+}
+
+// AddRefundedAmount raises the refunded total of a purchase entry, refusing
+// to let it pass the amount that was charged.
+func (r *walletRepository) AddRefundedAmount(ctx context.Context, id primitive.ObjectID, amount money.Amount) error {
+
+	filter := bson.M{
+		"_id":  id,
+		"type": "purchase",
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refunded_amount", 0}}, amount}},
+			bson.M{"$abs": "$amount"},
+		}},
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"refunded_amount": amount,
+		},
+		"$set": bson.M{
+			"updated_at": time.Now(),
+		},
+	}
+
+	result, err := r.collectionTransaction.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
 	}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	if result.MatchedCount == 0 {
+		return ErrRefundExceedsCharge
+	}
+
+	return nil
+}
+
+// CreateOrderCharge fails with ErrOrderAlreadyCharged when the order already
+// has a charge.
+func (r *walletRepository) CreateOrderCharge(ctx context.Context, charge *OrderCharge) error {
+	_, err := r.collectionOrder.InsertOne(ctx, charge)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrOrderAlreadyCharged
+	}
 	return err
-}
\ No newline at end of file
+}
+
+func (r *walletRepository) GetOrderCharge(ctx context.Context, orderID primitive.ObjectID) (*OrderCharge, error) {
+
+	var charge OrderCharge
+
+	err := r.collectionOrder.FindOne(ctx, bson.M{"_id": orderID}).Decode(&charge)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &charge, nil
+}
+
+// availableAtLeast matches wallets whose unreserved balance covers amount.
+// Wallets created before holds existed have no held_balance field.
+func availableAtLeast(amount money.Amount) bson.M {
+	return bson.M{"$gte": bson.A{
+		bson.M{"$subtract": bson.A{"$balance", bson.M{"$ifNull": bson.A{"$held_balance", 0}}}},
+		amount,
+	}}
+}
+
+// HoldBalance reserves amount of the available balance, failing with an
+// InsufficientFundsError when not enough is available.
+func (r *walletRepository) HoldBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error) {
+
+	filter := bson.M{
+		"user_id":     userID,
+		"wallet_type": walletType,
+		"$expr":       availableAtLeast(amount),
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"held_balance": amount,
+		},
+		"$set": bson.M{
+			"updated_at": time.Now(),
+		},
+	}
+
+	var wallet Wallet
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if err == mongo.ErrNoDocuments {
+		return nil, &InsufficientFundsError{UserID: userID, WalletType: walletType, Amount: amount}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// CaptureHeldBalance debits capture from a wallet and drops capture+release
+// from its held balance.
+func (r *walletRepository) CaptureHeldBalance(ctx context.Context, userID string, walletType string, capture money.Amount, release money.Amount) (*Wallet, error) {
+
+	filter := bson.M{
+		"user_id":      userID,
+		"wallet_type":  walletType,
+		"held_balance": bson.M{"$gte": capture + release},
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"balance":      -capture,
+			"held_balance": -(capture + release),
+		},
+		"$set": bson.M{
+			"updated_at": time.Now(),
+		},
+	}
+
+	var wallet Wallet
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func (r *walletRepository) ReleaseHeldBalance(ctx context.Context, userID string, walletType string, amount money.Amount) (*Wallet, error) {
+	return r.CaptureHeldBalance(ctx, userID, walletType, 0, amount)
+}
+
+func (r *walletRepository) CreateHold(ctx context.Context, hold *Hold) error {
+	_, err := r.collectionHold.InsertOne(ctx, hold)
+	return err
+}
+
+func (r *walletRepository) GetHold(ctx context.Context, id primitive.ObjectID) (*Hold, error) {
+
+	var hold Hold
+
+	err := r.collectionHold.FindOne(ctx, bson.M{"_id": id}).Decode(&hold)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+// CloseHold moves an active hold to its final status. It fails with
+// ErrHoldNotActive if another request closed the hold first.
+func (r *walletRepository) CloseHold(ctx context.Context, id primitive.ObjectID, status string, capturedAmount money.Amount) error {
+
+	filter := bson.M{
+		"_id":    id,
+		"status": HoldStatusActive,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":          status,
+			"captured_amount": capturedAmount,
+			"updated_at":      time.Now(),
+		},
+	}
+
+	result, err := r.collectionHold.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrHoldNotActive
+	}
+
+	return nil
+}
+
+func (r *walletRepository) GetExpiredHolds(ctx context.Context, now time.Time) ([]*Hold, error) {
+
+	var holds []*Hold
+
+	filter := bson.M{
+		"status":     HoldStatusActive,
+		"expires_at": bson.M{"$lte": now},
+	}
+
+	cursor, err := r.collectionHold.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &holds); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// AddTransferTotal raises the user's transfer total for day by amount,
+// failing with ErrTransferLimitExceeded when that would pass limit.
+func (r *walletRepository) AddTransferTotal(ctx context.Context, userID string, day time.Time, amount money.Amount, limit money.Amount) error {
+
+	if amount > limit {
+		return ErrTransferLimitExceeded
+	}
+
+	// The guard on total makes an existing total that cannot take the amount
+	// miss, and the upsert then collides with it on the unique index.
+	filter := bson.M{
+		"user_id": userID,
+		"day":     day.UTC().Format("2006-01-02"),
+		"total":   bson.M{"$lte": limit - amount},
+	}
+
+	update := bson.M{
+		"$inc":         bson.M{"total": amount},
+		"$setOnInsert": bson.M{"expires_at": day.UTC().Truncate(24 * time.Hour).Add(48 * time.Hour)},
+	}
+
+	_, err := r.collectionTransferTotal.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrTransferLimitExceeded
+	}
+
+	return err
+}
+
+// SetMissingCurrency sets currency on the wallets of walletType that have
+// none.
+func (r *walletRepository) SetMissingCurrency(ctx context.Context, walletType string, currency string) error {
+	filter := bson.M{
+		"wallet_type": walletType,
+		"$or": bson.A{
+			bson.M{"currency": bson.M{"$exists": false}},
+			bson.M{"currency": ""},
+		},
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"currency": currency}})
+	return err
+}
+
+// GetUnopenedWallets returns the wallets created before the ledger whose
+// account has not been opened yet.
+func (r *walletRepository) GetUnopenedWallets(ctx context.Context) ([]*Wallet, error) {
+
+	var wallets []*Wallet
+
+	cursor, err := r.collection.Find(ctx, bson.M{"ledger_opened_at": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// SetLedgerOpened marks a wallet account as opened. Inside a transaction the
+// write also conflicts with any movement of the wallet made meanwhile.
+func (r *walletRepository) SetLedgerOpened(ctx context.Context, id primitive.ObjectID) error {
+
+	filter := bson.M{
+		"_id":              id,
+		"ledger_opened_at": bson.M{"$exists": false},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"ledger_opened_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("wallet %s is already opened in the ledger", id.Hex())
+	}
+
+	return nil
+}
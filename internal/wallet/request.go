@@ -1,12 +1,126 @@
 package wallet
 
+import (
+	"time"
+	"wallet-service/pkg/money"
+)
+
+// AddBalanceRequest credits a wallet with Balance paid in Currency, which
+// is converted into the wallet's currency. Currency defaults to the wallet's
+// currency.
 type AddBalanceRequest struct {
-	UserID     string  `json:"user_id"`
-	WalletType string  `json:"wallet_type"`
-	Balance    float64 `json:"balance"`
+	UserID     string       `json:"user_id"`
+	WalletType string       `json:"wallet_type"`
+	Balance    money.Amount `json:"balance"`
+	Currency   string       `json:"currency"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
 }
 
+// DeductBalanceRequest charges a purchase in one of three forms: explicit
+// Legs, a single Amount split across wallets in Priority order, or the
+// older PriceStore/PriceService pair. SpendOn names what is being bought
+// and is required with Legs and Amount; every wallet used must be
+// spendable on it.
 type DeductBalanceRequest struct {
-	PriceStore   float64 `json:"price_store"`
-	PriceService float64 `json:"price_service"`
+	PriceStore   money.Amount `json:"price_store"`
+	PriceService money.Amount `json:"price_service"`
+
+	Legs     []DeductLeg  `json:"legs"`
+	Amount   money.Amount `json:"amount"`
+	Priority []string     `json:"priority"`
+	SpendOn  string       `json:"spend_on"`
+
+	// OrderID links the purchase to an order; an order can be charged once.
+	OrderID string      `json:"order_id"`
+	Items   []OrderItem `json:"items"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
+}
+
+// DeductLeg is the amount taken from one wallet.
+type DeductLeg struct {
+	WalletType string       `json:"wallet_type"`
+	Amount     money.Amount `json:"amount"`
+}
+
+// TransactionFilter holds the query parameters of the transaction history
+// endpoint. Results are ordered newest first; Cursor is the NextCursor of the
+// previous page.
+type TransactionFilter struct {
+	Type       string    `form:"type"`
+	WalletType string    `form:"wallet_type"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	OrderID    string    `form:"order_id"`
+	AdminID    string    `form:"admin_id"`
+	Limit      int64     `form:"limit"`
+	Cursor     string    `form:"cursor"`
+}
+
+// RefundRequest gives back money taken by a purchase. TransactionID is any
+// ledger entry of that purchase. Each leg refunds part of what was taken from
+// one wallet; without legs, everything not yet refunded is given back.
+type RefundRequest struct {
+	TransactionID string      `json:"transaction_id"`
+	Legs          []RefundLeg `json:"legs"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
+}
+
+type RefundLeg struct {
+	WalletType string       `json:"wallet_type"`
+	Amount     money.Amount `json:"amount"`
+}
+
+// OrderItem is optional line-item metadata stored with an order charge.
+type OrderItem struct {
+	ProductID string       `json:"product_id" bson:"product_id"`
+	Name      string       `json:"name" bson:"name"`
+	Quantity  int          `json:"quantity" bson:"quantity"`
+	Amount    money.Amount `json:"amount" bson:"amount"`
+}
+
+// CreateHoldRequest reserves funds for an order. ExpiresIn is in seconds;
+// zero uses the default hold lifetime.
+type CreateHoldRequest struct {
+	WalletType string       `json:"wallet_type"`
+	Amount     money.Amount `json:"amount"`
+	OrderID    string       `json:"order_id"`
+	ExpiresIn  int64        `json:"expires_in"`
+}
+
+// CaptureHoldRequest settles a hold. Without Amount the whole hold is
+// captured; otherwise the rest is released.
+type CaptureHoldRequest struct {
+	Amount *money.Amount `json:"amount"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
+}
+
+// TransferRequest sends Amount from the caller's WalletType wallet to the
+// ToWalletType wallet of ToUserID (the same type when empty).
+type TransferRequest struct {
+	ToUserID     string       `json:"to_user_id"`
+	WalletType   string       `json:"wallet_type"`
+	ToWalletType string       `json:"to_wallet_type"`
+	Amount       money.Amount `json:"amount"`
+	Note         string       `json:"note"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
+}
+
+// InternalTransferRequest moves Amount between two wallets of the caller.
+type InternalTransferRequest struct {
+	FromWalletType string       `json:"from_wallet_type"`
+	ToWalletType   string       `json:"to_wallet_type"`
+	Amount         money.Amount `json:"amount"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header.
+	IdempotencyKey string `json:"-"`
 }
@@ -1,11 +1,78 @@
 package wallet
 
+import (
+	"time"
+	"wallet-service/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WalletByUser lists a user's wallets. When a display currency was asked
+// for, Total is the sum of all balances converted into it.
 type WalletByUser struct {
-	UserID string `json:"user_id"`
-	Wallet []WalletUser `json:"wallet"`
+	UserID          string        `json:"user_id"`
+	Wallet          []WalletUser  `json:"wallet"`
+	DisplayCurrency string        `json:"display_currency,omitempty"`
+	Total           *money.Amount `json:"total,omitempty"`
 }
 
 type WalletUser struct {
-	Balance    float64            `json:"balance"`
-	WalletType string             `json:"wallet_type"`
+	Balance          money.Amount `json:"balance"`
+	HeldBalance      money.Amount `json:"held_balance"`
+	AvailableBalance money.Amount `json:"available_balance"`
+	WalletType       string       `json:"wallet_type"`
+	Currency         string       `json:"currency"`
+	// DisplayBalance is Balance in the requested display currency.
+	DisplayBalance *money.Amount `json:"display_balance,omitempty"`
+}
+
+
+// Deduction is the outcome of a purchase: the total charged and how much of
+// it came from each wallet.
+type Deduction struct {
+	OperationID  primitive.ObjectID `json:"operation_id"`
+	Total        money.Amount       `json:"total"`
+	Currency     string             `json:"currency"`
+	Breakdown    []DeductLeg        `json:"breakdown"`
+	Transactions []*Transactions    `json:"transactions"`
+}
+
+type TransactionPage struct {
+	Transactions []*Transactions `json:"transactions"`
+	NextCursor   string          `json:"next_cursor,omitempty"`
+}
+
+// ReconciliationReport is the outcome of one reconciliation run.
+type ReconciliationReport struct {
+	RunID          string             `json:"run_id"`
+	StartedAt      time.Time          `json:"started_at"`
+	FinishedAt     time.Time          `json:"finished_at"`
+	Repair         bool               `json:"repair"`
+	WalletsChecked int                `json:"wallets_checked"`
+	Repaired       int                `json:"repaired"`
+	Mismatches     []*BalanceMismatch `json:"mismatches"`
+	// Unopened counts wallets skipped because their ledger account has not
+	// been opened yet.
+	Unopened int `json:"unopened,omitempty"`
+}
+
+type BalanceMismatch struct {
+	WalletID   string       `json:"wallet_id"`
+	UserID     string       `json:"user_id"`
+	WalletType string       `json:"wallet_type"`
+	Balance    money.Amount `json:"balance"`
+	Expected   money.Amount `json:"expected"`
+	Difference money.Amount `json:"difference"`
+	// NoEntries is set when the journal account has no postings; such a
+	// wallet is never repaired.
+	NoEntries bool   `json:"no_entries,omitempty"`
+	Repaired  bool   `json:"repaired"`
+	Error     string `json:"error,omitempty"`
+}
+
+// OrderTransactions is every wallet movement linked to one order: the
+// purchase entries and any refunds.
+type OrderTransactions struct {
+	Order        *OrderCharge    `json:"order"`
+	Transactions []*Transactions `json:"transactions"`
 }
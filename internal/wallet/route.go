@@ -9,11 +9,19 @@ import (
 func RegisterRoutes(r *gin.Engine, handler *WalletHandler) {
 	walletGroup := r.Group("/api/v1/wallet") 
 	{
-		walletGroup.POST(":user_id", handler.CreateWallet)
+		walletGroup.POST(":user_id", middleware.Secured(), middleware.AuthorizeOwner("user_id", middleware.PermWalletCreateAny), handler.CreateWallet)
 		// walletGroup.GET("", handler.GetAllWallet)
-		walletGroup.GET("/:user_id", handler.GetWalletByUserID)
-		walletGroup.POST("/add_balance", middleware.Secured() , handler.AddBalance)
-		walletGroup.POST("/deduct_balance", middleware.Secured() , handler.DeductBalance)
+		walletGroup.GET("/:user_id", middleware.Secured(middleware.ScopeWalletRead), middleware.AuthorizeOwner("user_id", middleware.PermWalletReadAny), handler.GetWalletByUserID)
+		walletGroup.GET("/:user_id/transactions", middleware.Secured(middleware.ScopeWalletRead), middleware.AuthorizeOwner("user_id", middleware.PermWalletReadAny), handler.GetTransactions)
+		walletGroup.GET("/orders/:order_id/transactions", middleware.Secured(middleware.ScopeWalletRead), handler.GetOrderTransactions)
+		walletGroup.POST("/add_balance", middleware.Secured(), middleware.Authorize(middleware.PermWalletCredit), handler.AddBalance)
+		walletGroup.POST("/deduct_balance", middleware.Secured(middleware.ScopeWalletDeduct), handler.DeductBalance)
+		walletGroup.POST("/refund", middleware.Secured(), middleware.Authorize(middleware.PermWalletRefund), handler.Refund)
+		walletGroup.POST("/transfer", middleware.Secured(), handler.Transfer)
+		walletGroup.POST("/internal_transfer", middleware.Secured(), handler.InternalTransfer)
+		walletGroup.POST("/holds", middleware.Secured(middleware.ScopeWalletHold), handler.CreateHold)
+		walletGroup.POST("/holds/:hold_id/capture", middleware.Secured(middleware.ScopeWalletHold), handler.CaptureHold)
+		walletGroup.POST("/holds/:hold_id/release", middleware.Secured(middleware.ScopeWalletHold), handler.ReleaseHold)
 		// walletGroup.DELETE("/:id", handler.DeleteWallet)
 	}
 }
\ No newline at end of file
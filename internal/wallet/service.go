@@ -2,32 +2,58 @@ package wallet
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 	"wallet-service/internal/exchange"
+	"wallet-service/internal/ledger"
 	"wallet-service/internal/user"
+	"wallet-service/internal/wallettype"
+	"wallet-service/pkg/money"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type WalletService interface {
 	CreateWallet(ctx context.Context, userID string) error
 	GetWalletByUserID(ctx context.Context, userID string) (*WalletByUser, error)
-	AddBalance(ctx context.Context, req *AddBalanceRequest, userID string) error
-	DeductBalance(ctx context.Context, req *DeductBalanceRequest, userID string) error // Fixed typo
+	GetWalletInCurrency(ctx context.Context, userID string, currency string) (*WalletByUser, error)
+	MigrateCurrencies(ctx context.Context) error
+	MigrateOpeningBalances(ctx context.Context) error
+	AddBalance(ctx context.Context, req *AddBalanceRequest, userID string) (*Transactions, error)
+	DeductBalance(ctx context.Context, req *DeductBalanceRequest, userID string) (*Deduction, error)
+	GetTransactions(ctx context.Context, userID string, filter *TransactionFilter) (*TransactionPage, error)
+	Refund(ctx context.Context, req *RefundRequest, adminID string) ([]*Transactions, error)
+	GetOrderTransactions(ctx context.Context, orderID string) (*OrderTransactions, error)
+	CreateHold(ctx context.Context, req *CreateHoldRequest, userID string) (*Hold, error)
+	CaptureHold(ctx context.Context, holdID string, req *CaptureHoldRequest, userID string) ([]*Transactions, error)
+	ReleaseHold(ctx context.Context, holdID string, userID string) (*Hold, error)
+	ExpireHolds(ctx context.Context) (int, error)
+	Transfer(ctx context.Context, req *TransferRequest, userID string) ([]*Transactions, error)
+	InternalTransfer(ctx context.Context, req *InternalTransferRequest, userID string) ([]*Transactions, error)
 }
 
 type walletService struct {
-	walletRepo      WalletRepository
-	exchangeService exchange.ExchangeService
-	userService     user.UserService
+	walletRepo        WalletRepository
+	exchangeService   exchange.ExchangeService
+	walletTypeService wallettype.WalletTypeService
+	userService       user.UserService
+	ledgerService     ledger.LedgerService
+	settings          Settings
 }
 
-func NewWalletService(walletRepo WalletRepository, exchangeService exchange.ExchangeService, userService user.UserService) WalletService {
+func NewWalletService(walletRepo WalletRepository, exchangeService exchange.ExchangeService, walletTypeService wallettype.WalletTypeService, userService user.UserService, ledgerService ledger.LedgerService, settings Settings) WalletService {
 	return &walletService{
-		walletRepo:      walletRepo,
-		exchangeService: exchangeService,
-		userService:     userService,
+		walletRepo:        walletRepo,
+		exchangeService:   exchangeService,
+		walletTypeService: walletTypeService,
+		userService:       userService,
+		ledgerService:     ledgerService,
+		settings:          settings,
 	}
 }
 
@@ -43,37 +69,90 @@ func (s *walletService) CreateWallet(ctx context.Context, userID string) error {
 		return fmt.Errorf("wallet already exists for user %s", userID)
 	}
 
-	// Create store wallet
-	walletStore := &Wallet{
-		ID:         primitive.NewObjectID(),
-		UserID:     userID,
-		WalletType: "store",
-		Balance:    0,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
+	_, err = s.ensureWallets(ctx, userID, existingWallets)
+	return err
+}
 
-	err = s.walletRepo.CreateWallet(ctx, walletStore)
+// ensureWallets creates the wallets of every active catalog type the user is
+// missing, so a type added to the catalog reaches existing users the next
+// time their wallets are read. It returns all of the user's wallets.
+func (s *walletService) ensureWallets(ctx context.Context, userID string, wallets []*Wallet) ([]*Wallet, error) {
+
+	walletTypes, err := s.walletTypeService.GetActiveWalletTypes(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create store wallet: %w", err)
+		return nil, fmt.Errorf("failed to get wallet types: %w", err)
 	}
 
-	// Create service wallet
-	walletService := &Wallet{
-		ID:         primitive.NewObjectID(),
-		UserID:     userID,
-		WalletType: "service",
-		Balance:    0,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	existing := make(map[string]bool)
+	for _, wallet := range wallets {
+		existing[wallet.WalletType] = true
 	}
 
-	err = s.walletRepo.CreateWallet(ctx, walletService)
+	created := false
+	for _, walletType := range walletTypes {
+		if existing[walletType.Name] {
+			continue
+		}
+
+		now := time.Now()
+		err := s.walletRepo.CreateWallet(ctx, &Wallet{
+			ID:             primitive.NewObjectID(),
+			UserID:         userID,
+			WalletType:     walletType.Name,
+			Currency:       walletType.Currency,
+			Balance:        0,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			LedgerOpenedAt: &now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s wallet: %w", walletType.Name, err)
+		}
+		created = true
+	}
+
+	if !created {
+		return wallets, nil
+	}
+
+	return s.walletRepo.GetWalletByUserID(ctx, userID)
+}
+
+// activeWalletType looks a wallet type up in the catalog and refuses types
+// that are unknown or disabled.
+func (s *walletService) activeWalletType(ctx context.Context, name string) (*wallettype.WalletType, error) {
+
+	walletType, err := s.walletTypeService.GetWalletTypeByName(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to create service wallet: %w", err)
+		return nil, fmt.Errorf("invalid wallet_type: %w", err)
 	}
 
-	return nil
+	if !walletType.Active {
+		return nil, fmt.Errorf("wallet type %s is disabled", name)
+	}
+
+	return walletType, nil
+}
+
+// checkUser confirms the main service knows the user. When it cannot be
+// reached and degraded user checks are allowed, a user who already has
+// wallets is taken to exist; it must run before anything creates wallets
+// for the user.
+func (s *walletService) checkUser(ctx context.Context, userID string) error {
+
+	_, err := s.userService.GetUserInfor(ctx, userID)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, user.ErrUnavailable) && s.settings.DegradedUserCheck {
+		wallets, walletErr := s.walletRepo.GetWalletByUserID(ctx, userID)
+		if walletErr == nil && len(wallets) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("user %s: %w", userID, err)
 }
 
 func (s *walletService) GetWalletByUserID(ctx context.Context, userID string) (*WalletByUser, error) {
@@ -87,25 +166,20 @@ func (s *walletService) GetWalletByUserID(ctx context.Context, userID string) (*
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
 
-	// Create wallet if doesn't exist
-	if len(wallet) == 0 {
-		err := s.CreateWallet(ctx, userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create wallet: %w", err)
-		}
-
-		// Get wallet again after creation
-		wallet, err = s.walletRepo.GetWalletByUserID(ctx, userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get wallet after creation: %w", err)
-		}
+	// Create any wallet the catalog has and the user doesn't
+	wallet, err = s.ensureWallets(ctx, userID, wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
 
 	var walletUsers []WalletUser
 	for _, wal := range wallet {
 		walletUsers = append(walletUsers, WalletUser{
-			Balance:    wal.Balance,
-			WalletType: wal.WalletType,
+			Balance:          wal.Balance,
+			HeldBalance:      wal.HeldBalance,
+			AvailableBalance: wal.Balance - wal.HeldBalance,
+			WalletType:       wal.WalletType,
+			Currency:         wal.Currency,
 		})
 	}
 
@@ -115,199 +189,626 @@ func (s *walletService) GetWalletByUserID(ctx context.Context, userID string) (*
 	}, nil
 }
 
-func (s *walletService) AddBalance(ctx context.Context, req *AddBalanceRequest, userID string) error {
+// GetWalletInCurrency returns the user's wallets with every balance also
+// converted into currency, plus their total in that currency.
+func (s *walletService) GetWalletInCurrency(ctx context.Context, userID string, currency string) (*WalletByUser, error) {
+
+	walletByUser, err := s.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var total money.Amount
+	for i := range walletByUser.Wallet {
+		wal := &walletByUser.Wallet[i]
+
+		conversion, err := s.exchangeService.Convert(ctx, wal.Balance, wal.Currency, currency, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s wallet: %w", wal.WalletType, err)
+		}
+
+		wal.DisplayBalance = &conversion.Amount
+		total += conversion.Amount
+	}
+
+	walletByUser.DisplayCurrency = currency
+	walletByUser.Total = &total
+
+	return walletByUser, nil
+}
+
+// MigrateCurrencies gives wallets and journal entries created before they
+// had a currency the currency of their wallet type.
+func (s *walletService) MigrateCurrencies(ctx context.Context) error {
+
+	walletTypes, err := s.walletTypeService.GetAllWalletType(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, walletType := range walletTypes {
+		if err := s.walletRepo.SetMissingCurrency(ctx, walletType.Name, walletType.Currency); err != nil {
+			return fmt.Errorf("failed to set currency of %s wallets: %w", walletType.Name, err)
+		}
+		if err := s.ledgerService.MigrateWalletCurrency(ctx, walletType.Name, walletType.Currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MigrateOpeningBalances opens the ledger account of every wallet created
+// before the ledger, posting what the wallet holds beyond the entries already
+// on its account against ledger.OpeningBalanceAccount. Each wallet is opened
+// once, in a transaction with the post, so the account balance equals the
+// wallet balance from then on.
+func (s *walletService) MigrateOpeningBalances(ctx context.Context) error {
+
+	wallets, err := s.walletRepo.GetUnopenedWallets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, wallet := range wallets {
+		if err := s.openLedgerAccount(ctx, wallet.ID); err != nil {
+			return fmt.Errorf("failed to open ledger account of wallet %s: %w", wallet.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *walletService) openLedgerAccount(ctx context.Context, walletID primitive.ObjectID) error {
+
+	return s.walletRepo.WithTransaction(ctx, func(ctx context.Context) error {
+
+		wallet, err := s.walletRepo.GetWalletByID(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		if wallet.LedgerOpenedAt != nil {
+			return nil
+		}
+
+		walletAccount := ledger.WalletAccount(wallet.UserID, wallet.WalletType)
+		account, err := s.ledgerService.GetAccountBalance(ctx, walletAccount, wallet.Currency)
+		if err != nil {
+			return err
+		}
+
+		// Movements posted since the ledger went live are already on the
+		// account; only the rest is opening balance.
+		opening := wallet.Balance - account.Balance
+
+		var postings []ledger.Posting
+		switch {
+		case opening > 0:
+			postings = ledger.Transfer(ledger.OpeningBalanceAccount, walletAccount, opening)
+		case opening < 0:
+			postings = ledger.Transfer(walletAccount, ledger.OpeningBalanceAccount, -opening)
+		}
+
+		if postings != nil {
+			if _, err := s.ledgerService.Post(ctx, primitive.NewObjectID(), "opening_balance", wallet.Currency, postings); err != nil {
+				return err
+			}
+		}
+
+		return s.walletRepo.SetLedgerOpened(ctx, wallet.ID)
+	})
+}
+
+func (s *walletService) AddBalance(ctx context.Context, req *AddBalanceRequest, userID string) (*Transactions, error) {
 	// Validate input
 	if req == nil {
-		return fmt.Errorf("request is required")
+		return nil, fmt.Errorf("request is required")
 	}
 
 	if req.Balance <= 0 {
-		return fmt.Errorf("balance must be greater than 0")
+		return nil, fmt.Errorf("balance must be greater than 0")
 	}
 
 	if req.UserID == "" {
-		return fmt.Errorf("user_id is required")
+		return nil, fmt.Errorf("user_id is required")
 	}
 
 	if req.WalletType == "" {
-		return fmt.Errorf("wallet_type is required")
+		return nil, fmt.Errorf("wallet_type is required")
 	}
 
 	if userID == "" {
-		return fmt.Errorf("admin user_id is required")
+		return nil, fmt.Errorf("admin user_id is required")
 	}
 
 	// Validate wallet type
-	if req.WalletType != "store" && req.WalletType != "service" {
-		return fmt.Errorf("invalid wallet_type: %s", req.WalletType)
+	if _, err := s.activeWalletType(ctx, req.WalletType); err != nil {
+		return nil, err
 	}
 
 	// Check if user's wallet exists
 	_, err := s.GetWalletByUserID(ctx, req.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to get user wallet: %w", err)
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
 	}
 
-	// Get exchange rate
-	exchange, err := s.exchangeService.GetFirstExchangeRate(ctx)
+	target, err := s.walletRepo.GetBalanceUser(ctx, req.UserID, req.WalletType)
 	if err != nil {
-		return fmt.Errorf("failed to get exchange rate: %w", err)
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
 	}
 
-	if exchange.Rate <= 0 {
-		return fmt.Errorf("invalid exchange rate: %f", exchange.Rate)
+	// A deposit without a currency is paid in the wallet's own
+	if req.Currency == "" {
+		req.Currency = target.Currency
 	}
 
-	// Get current balance
-	wallet, err := s.walletRepo.GetBalanceUser(ctx, req.UserID, req.WalletType)
+	// Convert the deposit into the wallet's currency with the version of
+	// that pair's rate in effect now, rounding half away from zero to the
+	// nearest minor unit.
+	conversion, err := s.exchangeService.Convert(ctx, req.Balance, req.Currency, target.Currency, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to get current balance: %w", err)
+		return nil, fmt.Errorf("failed to convert deposit: %w", err)
 	}
 
-	// Calculate new balance (check for overflow)
-	addAmount := exchange.Rate * req.Balance
-	newBalance := wallet.Balance + addAmount
+	addAmount := conversion.Amount
 
-	// Basic overflow check
-	if newBalance < wallet.Balance {
-		return fmt.Errorf("balance overflow detected")
+	if addAmount <= 0 {
+		return nil, fmt.Errorf("deposit of %s %s is too small to credit", req.Balance, req.Currency)
 	}
 
-	// Update balance
-	err = s.walletRepo.AddBalance(ctx, req.UserID, req.WalletType, newBalance)
-	if err != nil {
-		return fmt.Errorf("failed to update balance: %w", err)
-	}
+	// Credit the wallet and record the deposit together; the increment is
+	// applied atomically so concurrent top-ups are never lost. The admin's
+	// Idempotency-Key makes a double-submitted top-up credit only once.
+	transactions, err := s.runIdempotent(ctx, userID, operationAddBalance, req.IdempotencyKey, req, func(ctx context.Context) ([]*Transactions, error) {
 
-	// Create transaction record
-	transaction := &Transactions{
-		ID:        primitive.NewObjectID(),
-		UserID:    req.UserID,
-		Type:      "deposit",
-		Money:     &req.Balance,
-		Amount:    addAmount,
-		Currency:  &exchange.Currency,
-		AdminID:   &userID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(), // Added UpdatedAt
-	}
+		operationID := primitive.NewObjectID()
 
-	err = s.walletRepo.CreateTransaction(ctx, transaction)
+		wallet, err := s.walletRepo.AddBalance(ctx, req.UserID, req.WalletType, addAmount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		// Create transaction record
+		transaction := newLedgerEntry(operationID, "deposit", wallet, addAmount)
+		transaction.Money = &req.Balance
+		transaction.Currency = &req.Currency
+		if conversion.Rate != nil {
+			transaction.ExchangeRateID = &conversion.Rate.ID
+		}
+		transaction.AdminID = &userID
+		transaction.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+		err = s.walletRepo.CreateTransaction(ctx, transaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transaction: %w", err)
+		}
+
+		// The credit is funded by the admin who made the top-up
+		postings := ledger.Transfer(ledger.FundingAccount(userID), ledger.WalletAccount(req.UserID, req.WalletType), addAmount)
+		if _, err := s.ledgerService.Post(ctx, operationID, "deposit", wallet.Currency, postings); err != nil {
+			return nil, err
+		}
+
+		return []*Transactions{transaction}, nil
+	})
 	if err != nil {
-		// TODO: Consider rollback balance update
-		return fmt.Errorf("failed to create transaction: %w", err)
+		return nil, err
 	}
 
-	return nil
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("deposit wrote no transaction")
+	}
+
+	return transactions[0], nil
 }
 
-func (s *walletService) DeductBalance(ctx context.Context, req *DeductBalanceRequest, userID string) error {
+func (s *walletService) DeductBalance(ctx context.Context, req *DeductBalanceRequest, userID string) (*Deduction, error) {
 	// Validate input
 	if req == nil {
-		return fmt.Errorf("request is required")
+		return nil, fmt.Errorf("request is required")
 	}
 
 	if userID == "" {
-		return fmt.Errorf("user_id is required")
+		return nil, fmt.Errorf("user_id is required")
 	}
 
-	if req.PriceService < 0 || req.PriceStore < 0 {
-		return fmt.Errorf("prices cannot be negative")
+	plan, err := s.planDeduction(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
-	if req.PriceService == 0 && req.PriceStore == 0 {
-		return fmt.Errorf("at least one price must be greater than 0")
+	var orderID *primitive.ObjectID
+	if req.OrderID != "" {
+		id, err := primitive.ObjectIDFromHex(req.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order_id: %w", err)
+		}
+		orderID = &id
 	}
 
-	// Get exchange rate
-	exchange, err := s.exchangeService.GetFirstExchangeRate(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get exchange rate: %w", err)
+	if err := s.checkUser(ctx, userID); err != nil {
+		return nil, err
 	}
 
 	_, err = s.GetWalletByUserID(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Debit the wallets and record the purchase as one unit of work so a
+	// failure part way through leaves no partial debit. Each debit refuses to
+	// overdraw on its own, returning an InsufficientFundsError, unless the
+	// catalog lets that wallet type go negative. A retried request carrying
+	// the same Idempotency-Key returns the original purchase.
+	transactions, err := s.runIdempotent(ctx, userID, operationDeductBalance, req.IdempotencyKey, req, func(ctx context.Context) ([]*Transactions, error) {
+
+		operationID := primitive.NewObjectID()
+
+		// Claim the order first so a second charge fails before any debit
+		if orderID != nil {
+			err := s.walletRepo.CreateOrderCharge(ctx, &OrderCharge{
+				OrderID:     *orderID,
+				OperationID: operationID,
+				UserID:      userID,
+				Items:       req.Items,
+				CreatedAt:   time.Now(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// A priority split is worked out against the balances read in
+		// this transaction; the conditional debits below still guard
+		// against anything spent concurrently.
+		debits := plan.debits
+		if plan.priority != nil {
+			wallets, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get wallets: %w", err)
+			}
+
+			debits, err = splitByPriority(userID, wallets, plan.priority, req.Amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var transactions []*Transactions
+		var postings []ledger.Posting
+		var total money.Amount
+		var currency string
+
+		// One ledger entry per debited wallet
+		for _, debit := range debits {
+			wallet, err := s.walletRepo.DeductBalance(ctx, debit.amount, debit.walletType.Name, userID, debit.walletType.AllowNegative)
+			if err != nil {
+				return nil, fmt.Errorf("failed to deduct from %s wallet: %w", debit.walletType.Name, err)
+			}
+
+			transaction := newLedgerEntry(operationID, "purchase", wallet, -debit.amount)
+			transaction.OrderID = orderID
+			transaction.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+			err = s.walletRepo.CreateTransaction(ctx, transaction)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create transaction: %w", err)
+			}
+
+			transactions = append(transactions, transaction)
+			postings = append(postings, ledger.Posting{Account: ledger.WalletAccount(userID, debit.walletType.Name), Debit: debit.amount})
+			total += debit.amount
+			currency = wallet.Currency
+		}
+
+		// Everything spent is credited to platform revenue
+		postings = append(postings, ledger.Posting{Account: ledger.RevenueAccount, Credit: total})
+		if _, err := s.ledgerService.Post(ctx, operationID, "purchase", currency, postings); err != nil {
+			return nil, err
+		}
+
+		return transactions, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	dataUser, err := s.userService.GetAllUser(ctx)
+	return newDeduction(transactions), nil
+}
+
+func (s *walletService) Refund(ctx context.Context, req *RefundRequest, adminID string) ([]*Transactions, error) {
+	// Validate input
+	if req == nil {
+		return nil, fmt.Errorf("request is required")
+	}
+
+	if adminID == "" {
+		return nil, fmt.Errorf("admin user_id is required")
+	}
+
+	transactionID, err := primitive.ObjectIDFromHex(req.TransactionID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid transaction_id: %w", err)
 	}
 
-	found := false
-	for _, item := range dataUser {
-		if item.UserID == userID {
-			found = true
-			break
-		}
+	original, err := s.walletRepo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
 
-	if !found {
-		return fmt.Errorf("user id not found")
+	if original.Type != "purchase" {
+		return nil, fmt.Errorf("only purchases can be refunded")
 	}
 
-	// Check service wallet balance if needed
-	if req.PriceService > 0 {
-		serviceWallet, err := s.walletRepo.GetBalanceUser(ctx, userID, "service")
-		if err != nil {
-			return fmt.Errorf("failed to get service wallet balance: %w", err)
+	if original.OperationID.IsZero() || original.WalletType == "" {
+		return nil, fmt.Errorf("transaction %s predates per-wallet entries and cannot be refunded", req.TransactionID)
+	}
+
+	// Every wallet the purchase took money from
+	entries, err := s.walletRepo.GetTransactionsByOperationID(ctx, original.OperationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase entries: %w", err)
+	}
+
+	purchases := make(map[string]*Transactions)
+	for _, entry := range entries {
+		if entry.Type == "purchase" {
+			purchases[entry.WalletType] = entry
 		}
+	}
 
-		if serviceWallet.Balance < req.PriceService {
-			return fmt.Errorf("insufficient service wallet balance: have %f, need %f",
-				serviceWallet.Balance, req.PriceService)
+	legs := req.Legs
+	if len(legs) == 0 {
+		for _, entry := range entries {
+			if remaining := -entry.Amount - entry.RefundedAmount; entry.Type == "purchase" && remaining > 0 {
+				legs = append(legs, RefundLeg{WalletType: entry.WalletType, Amount: remaining})
+			}
+		}
+		if len(legs) == 0 {
+			return nil, fmt.Errorf("purchase is already fully refunded")
 		}
 	}
 
-	// Check store wallet balance if needed
-	if req.PriceStore > 0 {
-		storeWallet, err := s.walletRepo.GetBalanceUser(ctx, userID, "store")
-		if err != nil {
-			return fmt.Errorf("failed to get store wallet balance: %w", err)
+	seen := make(map[string]bool)
+	for _, leg := range legs {
+		if _, ok := purchases[leg.WalletType]; !ok {
+			return nil, fmt.Errorf("purchase did not charge the %s wallet", leg.WalletType)
+		}
+		if leg.Amount <= 0 {
+			return nil, fmt.Errorf("refund amount must be greater than 0")
+		}
+		if seen[leg.WalletType] {
+			return nil, fmt.Errorf("duplicate refund leg for %s wallet", leg.WalletType)
+		}
+		seen[leg.WalletType] = true
+	}
+
+	// Give the money back to the wallets it came from, without any rate
+	// conversion. Each purchase entry tracks how much of it was refunded, so
+	// the total can never exceed what was charged.
+	return s.runIdempotent(ctx, adminID, operationRefund, req.IdempotencyKey, req, func(ctx context.Context) ([]*Transactions, error) {
+
+		operationID := primitive.NewObjectID()
+
+		var transactions []*Transactions
+		var postings []ledger.Posting
+		var total money.Amount
+		var currency string
+
+		for _, leg := range legs {
+			purchase := purchases[leg.WalletType]
+
+			err := s.walletRepo.AddRefundedAmount(ctx, purchase.ID, leg.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to refund %s wallet: %w", leg.WalletType, err)
+			}
+
+			wallet, err := s.walletRepo.AddBalance(ctx, purchase.UserID, leg.WalletType, leg.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to credit %s wallet: %w", leg.WalletType, err)
+			}
+
+			transaction := newLedgerEntry(operationID, "refund", wallet, leg.Amount)
+			transaction.Currency = purchase.Currency
+			transaction.OrderID = purchase.OrderID
+			transaction.AdminID = &adminID
+			transaction.ReversalOf = &purchase.ID
+			transaction.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+			err = s.walletRepo.CreateTransaction(ctx, transaction)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create transaction: %w", err)
+			}
+
+			transactions = append(transactions, transaction)
+			postings = append(postings, ledger.Posting{Account: ledger.WalletAccount(purchase.UserID, leg.WalletType), Credit: leg.Amount})
+			total += leg.Amount
+			currency = wallet.Currency
 		}
 
-		if storeWallet.Balance < req.PriceStore {
-			return fmt.Errorf("insufficient store wallet balance: have %f, need %f",
-				storeWallet.Balance, req.PriceStore)
+		// Refunds are paid back out of platform revenue
+		postings = append(postings, ledger.Posting{Account: ledger.RevenueAccount, Debit: total})
+		if _, err := s.ledgerService.Post(ctx, operationID, "refund", currency, postings); err != nil {
+			return nil, err
 		}
+
+		return transactions, nil
+	})
+}
+
+func (s *walletService) GetOrderTransactions(ctx context.Context, orderID string) (*OrderTransactions, error) {
+
+	id, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	order, err := s.walletRepo.GetOrderCharge(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	transactions, err := s.walletRepo.GetTransactions(ctx, bson.M{"order_id": id}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order transactions: %w", err)
+	}
+
+	if transactions == nil {
+		transactions = []*Transactions{}
 	}
 
-	// Deduct from service wallet
-	if req.PriceService > 0 {
-		err = s.walletRepo.DeductBalance(ctx, req.PriceService, "service", userID)
+	return &OrderTransactions{
+		Order:        order,
+		Transactions: transactions,
+	}, nil
+}
+
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+func (s *walletService) GetTransactions(ctx context.Context, userID string, filter *TransactionFilter) (*TransactionPage, error) {
+	// Validate input
+	if userID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+
+	if filter == nil {
+		filter = &TransactionFilter{}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	if limit > maxTransactionPageSize {
+		limit = maxTransactionPageSize
+	}
+
+	conditions := bson.A{bson.M{"user_id": userID}}
+
+	if filter.Type != "" {
+		conditions = append(conditions, bson.M{"type": filter.Type})
+	}
+
+	if filter.WalletType != "" {
+		conditions = append(conditions, bson.M{"wallet_type": filter.WalletType})
+	}
+
+	if filter.AdminID != "" {
+		conditions = append(conditions, bson.M{"admin_id": filter.AdminID})
+	}
+
+	if filter.OrderID != "" {
+		orderID, err := primitive.ObjectIDFromHex(filter.OrderID)
 		if err != nil {
-			return fmt.Errorf("failed to deduct from service wallet: %w", err)
+			return nil, fmt.Errorf("invalid order_id: %w", err)
+		}
+		conditions = append(conditions, bson.M{"order_id": orderID})
+	}
+
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		createdAt := bson.M{}
+		if !filter.From.IsZero() {
+			createdAt["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			createdAt["$lt"] = filter.To
 		}
+		conditions = append(conditions, bson.M{"created_at": createdAt})
 	}
 
-	// Deduct from store wallet
-	if req.PriceStore > 0 {
-		err = s.walletRepo.DeductBalance(ctx, req.PriceStore, "store", userID)
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
 		if err != nil {
-			// TODO: Rollback service wallet deduction
-			return fmt.Errorf("failed to deduct from store wallet: %w", err)
+			return nil, err
 		}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"created_at": bson.M{"$lt": createdAt}},
+			bson.M{"created_at": createdAt, "_id": bson.M{"$lt": id}},
+		}})
 	}
 
-	// Create transaction record
-	totalAmount := req.PriceService + req.PriceStore
-	transaction := &Transactions{
-		ID:        primitive.NewObjectID(),
-		UserID:    userID,
-		Type:      "purchase",
-		Amount:    totalAmount,
-		Money:     nil,
-		Currency:  &exchange.Currency,
-		AdminID:   nil,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// Fetch one extra row to know whether another page exists.
+	transactions, err := s.walletRepo.GetTransactions(ctx, bson.M{"$and": conditions}, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	page := &TransactionPage{Transactions: transactions}
+	if int64(len(transactions)) > limit {
+		page.Transactions = transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
 	}
 
-	err = s.walletRepo.CreateTransaction(ctx, transaction)
+	if page.Transactions == nil {
+		page.Transactions = []*Transactions{}
+	}
+
+	return page, nil
+}
+
+// encodeCursor packs the sort key of the last row of a page. Mongo stores
+// times with millisecond precision, which the cursor keeps.
+func encodeCursor(createdAt time.Time, id primitive.ObjectID) string {
+	raw := fmt.Sprintf("%d:%s", createdAt.UnixMilli(), id.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		return fmt.Errorf("failed to create transaction: %w", err)
+		return time.Time{}, primitive.NilObjectID, fmt.Errorf("invalid cursor")
 	}
 
-	return nil
+	millis, hex, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, primitive.NilObjectID, fmt.Errorf("invalid cursor")
+	}
+
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, fmt.Errorf("invalid cursor")
+	}
+
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, fmt.Errorf("invalid cursor")
+	}
+
+	return time.UnixMilli(ms).UTC(), id, nil
+}
+
+// newLedgerEntry builds the entry for a wallet that has just been moved by
+// amount (negative for debits); wallet is the state after the update.
+func newLedgerEntry(operationID primitive.ObjectID, transactionType string, wallet *Wallet, amount money.Amount) *Transactions {
+
+	balanceAfter := wallet.Balance
+	balanceBefore := balanceAfter - amount
+
+	return &Transactions{
+		ID:             primitive.NewObjectID(),
+		OperationID:    operationID,
+		UserID:         wallet.UserID,
+		Type:           transactionType,
+		WalletType:     wallet.WalletType,
+		Amount:         amount,
+		WalletCurrency: wallet.Currency,
+		BalanceBefore:  &balanceBefore,
+		BalanceAfter:   &balanceAfter,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
@@ -0,0 +1,31 @@
+package wallet
+
+import (
+	"strings"
+	"wallet-service/pkg/money"
+)
+
+// Settings holds the configurable business rules of the wallet service. A
+// zero limit means no limit.
+type Settings struct {
+	// TransferMaxAmount caps a single user-to-user transfer.
+	TransferMaxAmount money.Amount
+	// TransferDailyLimit caps what one user can send per UTC day.
+	TransferDailyLimit money.Amount
+	// InternalTransfers lists the directions a user may move funds between
+	// their own wallets, as "from:to" pairs such as "service:store".
+	InternalTransfers []string
+	// DegradedUserCheck lets a user who already has wallets through the
+	// user existence check while the main service is unavailable.
+	DegradedUserCheck bool
+}
+
+func (s Settings) allowsInternalTransfer(from string, to string) bool {
+	for _, direction := range s.InternalTransfers {
+		allowedFrom, allowedTo, ok := strings.Cut(direction, ":")
+		if ok && allowedFrom == from && allowedTo == to {
+			return true
+		}
+	}
+	return false
+}
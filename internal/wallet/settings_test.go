@@ -0,0 +1,32 @@
+package wallet
+
+import "testing"
+
+func TestAllowsInternalTransfer(t *testing.T) {
+	tests := []struct {
+		name      string
+		transfers []string
+		from      string
+		to        string
+		want      bool
+	}{
+		{name: "none configured", from: "service", to: "store", want: false},
+		{name: "allowed direction", transfers: []string{"service:store"}, from: "service", to: "store", want: true},
+		{name: "reverse of allowed direction", transfers: []string{"service:store"}, from: "store", to: "service", want: false},
+		{name: "both directions", transfers: []string{"service:store", "store:service"}, from: "store", to: "service", want: true},
+		{name: "second of several", transfers: []string{"bonus:store", "service:store"}, from: "service", to: "store", want: true},
+		{name: "entry without separator", transfers: []string{"servicestore"}, from: "service", to: "store", want: false},
+		{name: "entry with empty side", transfers: []string{"service:"}, from: "service", to: "store", want: false},
+		{name: "prefix of a wallet type", transfers: []string{"serv:store"}, from: "service", to: "store", want: false},
+		{name: "case sensitive", transfers: []string{"Service:Store"}, from: "service", to: "store", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{InternalTransfers: tt.transfers}
+			if got := settings.allowsInternalTransfer(tt.from, tt.to); got != tt.want {
+				t.Errorf("allowsInternalTransfer(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDeductBalanceCommitsDebitsAndEntriesTogether(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.fund(t, "u1", "store", 1000)
+	env.fund(t, "u1", "service", 500)
+
+	deduction, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 300, PriceService: 200}, "u1")
+	if err != nil {
+		t.Fatalf("DeductBalance: %v", err)
+	}
+
+	if got := env.balance(t, "u1", "store"); got != 700 {
+		t.Errorf("store balance = %s, want 7.00", got)
+	}
+	if got := env.balance(t, "u1", "service"); got != 300 {
+		t.Errorf("service balance = %s, want 3.00", got)
+	}
+	if n := env.count(t, "transactions", bson.M{"operation_id": deduction.OperationID}); n != 2 {
+		t.Errorf("purchase entries = %d, want 2", n)
+	}
+	if n := env.count(t, "journal_entries", bson.M{"operation_id": deduction.OperationID}); n != 1 {
+		t.Errorf("journal entries = %d, want 1", n)
+	}
+}
+
+func TestDeductBalanceRollsBackEarlierDebitsOnFailure(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// The service leg, debited first, can be paid; the store leg cannot,
+	// so the service debit must be rolled back with everything else.
+	env.fund(t, "u1", "store", 100)
+	env.fund(t, "u1", "service", 1000)
+
+	before := env.count(t, "transactions", bson.M{})
+	journalBefore := env.count(t, "journal_entries", bson.M{})
+
+	_, err := env.service.DeductBalance(ctx, &DeductBalanceRequest{PriceStore: 300, PriceService: 200}, "u1")
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("DeductBalance error = %v, want ErrInsufficientFunds", err)
+	}
+
+	if got := env.balance(t, "u1", "service"); got != 1000 {
+		t.Errorf("service balance = %s, want 10.00 after rollback", got)
+	}
+	if got := env.balance(t, "u1", "store"); got != 100 {
+		t.Errorf("store balance = %s, want 1.00 after rollback", got)
+	}
+	if n := env.count(t, "transactions", bson.M{}); n != before {
+		t.Errorf("transactions = %d, want %d after rollback", n, before)
+	}
+	if n := env.count(t, "journal_entries", bson.M{}); n != journalBefore {
+		t.Errorf("journal entries = %d, want %d after rollback", n, journalBefore)
+	}
+}
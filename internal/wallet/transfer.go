@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/ledger"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Transfer moves money from one of the caller's wallets to another user's
+// wallet. Both sides are written in one unit of work as a transfer_out and a
+// transfer_in entry sharing an OperationID.
+func (s *walletService) Transfer(ctx context.Context, req *TransferRequest, userID string) ([]*Transactions, error) {
+	// Validate input
+	if req == nil {
+		return nil, fmt.Errorf("request is required")
+	}
+
+	if userID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+
+	if req.ToUserID == "" {
+		return nil, fmt.Errorf("to_user_id is required")
+	}
+
+	if req.ToUserID == userID {
+		return nil, fmt.Errorf("cannot transfer to yourself")
+	}
+
+	if req.WalletType == "" {
+		return nil, fmt.Errorf("wallet_type is required")
+	}
+
+	toWalletType := req.ToWalletType
+	if toWalletType == "" {
+		toWalletType = req.WalletType
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+
+	if s.settings.TransferMaxAmount > 0 && req.Amount > s.settings.TransferMaxAmount {
+		return nil, fmt.Errorf("%w: a single transfer cannot exceed %s", ErrTransferLimitExceeded, s.settings.TransferMaxAmount)
+	}
+
+	if err := s.checkSameCurrency(ctx, req.WalletType, toWalletType); err != nil {
+		return nil, err
+	}
+
+	// The recipient must be a known user
+	if err := s.checkUser(ctx, req.ToUserID); err != nil {
+		return nil, fmt.Errorf("recipient not found: %w", err)
+	}
+
+	if _, err := s.GetWalletByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetWalletByUserID(ctx, req.ToUserID); err != nil {
+		return nil, err
+	}
+
+	return s.runIdempotent(ctx, userID, operationTransfer, req.IdempotencyKey, req, func(ctx context.Context) ([]*Transactions, error) {
+
+		operationID := primitive.NewObjectID()
+
+		// The daily total is one document per user raised in this
+		// transaction, so concurrent transfers conflict on it even when
+		// they draw from different wallets.
+		if s.settings.TransferDailyLimit > 0 {
+			err := s.walletRepo.AddTransferTotal(ctx, userID, time.Now(), req.Amount, s.settings.TransferDailyLimit)
+			if errors.Is(err, ErrTransferLimitExceeded) {
+				return nil, fmt.Errorf("%w: daily transfer limit of %s reached", ErrTransferLimitExceeded, s.settings.TransferDailyLimit)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to check daily transfer total: %w", err)
+			}
+		}
+
+		from, err := s.walletRepo.DeductBalance(ctx, req.Amount, req.WalletType, userID, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to debit %s wallet: %w", req.WalletType, err)
+		}
+
+		to, err := s.walletRepo.AddBalance(ctx, req.ToUserID, toWalletType, req.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to credit recipient %s wallet: %w", toWalletType, err)
+		}
+
+		out := newLedgerEntry(operationID, "transfer_out", from, -req.Amount)
+		out.CounterpartyUserID = req.ToUserID
+		out.Note = req.Note
+		out.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+		in := newLedgerEntry(operationID, "transfer_in", to, req.Amount)
+		in.CounterpartyUserID = userID
+		in.Note = req.Note
+
+		for _, transaction := range []*Transactions{out, in} {
+			if err := s.walletRepo.CreateTransaction(ctx, transaction); err != nil {
+				return nil, fmt.Errorf("failed to create transaction: %w", err)
+			}
+		}
+
+		postings := ledger.Transfer(ledger.WalletAccount(userID, req.WalletType), ledger.WalletAccount(req.ToUserID, toWalletType), req.Amount)
+		if _, err := s.ledgerService.Post(ctx, operationID, "transfer", from.Currency, postings); err != nil {
+			return nil, err
+		}
+
+		return []*Transactions{out, in}, nil
+	})
+}
+
+// InternalTransfer moves money between two wallets of the same user, in a
+// direction allowed by Settings.InternalTransfers.
+func (s *walletService) InternalTransfer(ctx context.Context, req *InternalTransferRequest, userID string) ([]*Transactions, error) {
+	// Validate input
+	if req == nil {
+		return nil, fmt.Errorf("request is required")
+	}
+
+	if userID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+
+	if req.FromWalletType == "" || req.ToWalletType == "" {
+		return nil, fmt.Errorf("from_wallet_type and to_wallet_type are required")
+	}
+
+	if req.FromWalletType == req.ToWalletType {
+		return nil, fmt.Errorf("from_wallet_type and to_wallet_type must differ")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+
+	if !s.settings.allowsInternalTransfer(req.FromWalletType, req.ToWalletType) {
+		return nil, fmt.Errorf("moving funds from %s to %s is not allowed", req.FromWalletType, req.ToWalletType)
+	}
+
+	if err := s.checkSameCurrency(ctx, req.FromWalletType, req.ToWalletType); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetWalletByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.runIdempotent(ctx, userID, operationInternalTransfer, req.IdempotencyKey, req, func(ctx context.Context) ([]*Transactions, error) {
+
+		operationID := primitive.NewObjectID()
+
+		from, err := s.walletRepo.DeductBalance(ctx, req.Amount, req.FromWalletType, userID, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to debit %s wallet: %w", req.FromWalletType, err)
+		}
+
+		to, err := s.walletRepo.AddBalance(ctx, userID, req.ToWalletType, req.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to credit %s wallet: %w", req.ToWalletType, err)
+		}
+
+		out := newLedgerEntry(operationID, "internal_transfer", from, -req.Amount)
+		out.IdempotencyKey = optionalString(req.IdempotencyKey)
+
+		in := newLedgerEntry(operationID, "internal_transfer", to, req.Amount)
+
+		for _, transaction := range []*Transactions{out, in} {
+			if err := s.walletRepo.CreateTransaction(ctx, transaction); err != nil {
+				return nil, fmt.Errorf("failed to create transaction: %w", err)
+			}
+		}
+
+		postings := ledger.Transfer(ledger.WalletAccount(userID, req.FromWalletType), ledger.WalletAccount(userID, req.ToWalletType), req.Amount)
+		if _, err := s.ledgerService.Post(ctx, operationID, "internal_transfer", from.Currency, postings); err != nil {
+			return nil, err
+		}
+
+		return []*Transactions{out, in}, nil
+	})
+}
+
+// checkSameCurrency validates both wallet types against the catalog and
+// refuses to move money between wallets of different currencies, which
+// transfers do not convert.
+func (s *walletService) checkSameCurrency(ctx context.Context, fromWalletType string, toWalletType string) error {
+
+	from, err := s.activeWalletType(ctx, fromWalletType)
+	if err != nil {
+		return err
+	}
+
+	to, err := s.activeWalletType(ctx, toWalletType)
+	if err != nil {
+		return err
+	}
+
+	if from.Currency != to.Currency {
+		return fmt.Errorf("cannot transfer between %s and %s wallets of different currencies", from.Name, to.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckSameCurrency(t *testing.T) {
+	s := &walletService{walletTypeService: testCatalog()}
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{name: "same currency", from: "service", to: "store"},
+		{name: "different currencies", from: "store", to: "usd", wantErr: true},
+		{name: "unknown wallet type", from: "store", to: "gems", wantErr: true},
+		{name: "inactive wallet type", from: "retired", to: "store", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkSameCurrency(context.Background(), tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSameCurrency(%q, %q) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Both transfers are refused before any wallet is read, so no repository is needed.
+func TestTransfersRejectCrossCurrency(t *testing.T) {
+	s := &walletService{
+		walletTypeService: testCatalog(),
+		settings:          Settings{InternalTransfers: []string{"store:usd"}},
+	}
+	ctx := context.Background()
+
+	if _, err := s.InternalTransfer(ctx, &InternalTransferRequest{FromWalletType: "store", ToWalletType: "usd", Amount: 100}, "u1"); err == nil {
+		t.Error("InternalTransfer moved funds between wallets of different currencies")
+	}
+
+	if _, err := s.Transfer(ctx, &TransferRequest{ToUserID: "u2", WalletType: "store", ToWalletType: "usd", Amount: 100}, "u1"); err == nil {
+		t.Error("Transfer moved funds between wallets of different currencies")
+	}
+}
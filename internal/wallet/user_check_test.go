@@ -0,0 +1,70 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"wallet-service/internal/user"
+)
+
+// usersAnswering returns err for every user, or finds them when err is nil.
+type usersAnswering struct {
+	err error
+}
+
+func (u usersAnswering) GetUserInfor(ctx context.Context, userID string) (*user.UserInfor, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	return &user.UserInfor{UserID: userID}, nil
+}
+
+// walletsOf is a WalletRepository that only knows the wallets of each user.
+type walletsOf struct {
+	WalletRepository
+	wallets map[string][]*Wallet
+}
+
+func (r walletsOf) GetWalletByUserID(ctx context.Context, userID string) ([]*Wallet, error) {
+	return r.wallets[userID], nil
+}
+
+func TestCheckUser(t *testing.T) {
+	repo := walletsOf{wallets: map[string][]*Wallet{
+		"with-wallets": {{UserID: "with-wallets", WalletType: "store"}},
+	}}
+
+	tests := []struct {
+		name     string
+		userErr  error
+		degraded bool
+		userID   string
+		wantErr  error
+	}{
+		{name: "found", userID: "with-wallets"},
+		{name: "found with degraded check", degraded: true, userID: "new"},
+		{name: "not found", userErr: user.ErrUserNotFound, userID: "new", wantErr: user.ErrUserNotFound},
+		{name: "not found with wallets and degraded check", userErr: user.ErrUserNotFound, degraded: true, userID: "with-wallets", wantErr: user.ErrUserNotFound},
+		{name: "unavailable", userErr: user.ErrUnavailable, userID: "with-wallets", wantErr: user.ErrUnavailable},
+		{name: "unavailable with wallets and degraded check", userErr: user.ErrUnavailable, degraded: true, userID: "with-wallets"},
+		{name: "unavailable without wallets and degraded check", userErr: user.ErrUnavailable, degraded: true, userID: "new", wantErr: user.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &walletService{
+				walletRepo:  repo,
+				userService: usersAnswering{err: tt.userErr},
+				settings:    Settings{DegradedUserCheck: tt.degraded},
+			}
+
+			err := s.checkUser(context.Background(), tt.userID)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("checkUser: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkUser error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package wallettype
+
+import "errors"
+
+// ErrWalletTypeExists is returned when a wallet type with the same name is
+// already in the catalog.
+var ErrWalletTypeExists = errors.New("wallet type already exists")
+
+// ErrWalletTypeNotFound is returned when a name or id is not in the catalog.
+var ErrWalletTypeNotFound = errors.New("wallet type not found")
@@ -0,0 +1,110 @@
+package wallettype
+
+import (
+	"errors"
+	"net/http"
+	"wallet-service/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletTypeHandler struct {
+	walletTypeService WalletTypeService
+}
+
+func NewWalletTypeHandler(walletTypeService WalletTypeService) *WalletTypeHandler {
+	return &WalletTypeHandler{
+		walletTypeService: walletTypeService,
+	}
+}
+
+func (h *WalletTypeHandler) CreateWalletType(c *gin.Context) {
+
+	var req CreateWalletTypeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	err := h.walletTypeService.CreateWalletType(c, &req)
+	if err != nil {
+		sendWalletTypeError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", nil)
+
+}
+
+func (h *WalletTypeHandler) GetAllWalletType(c *gin.Context) {
+
+	walletTypes, err := h.walletTypeService.GetAllWalletType(c)
+	if err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", walletTypes)
+
+}
+
+func (h *WalletTypeHandler) GetWalletType(c *gin.Context) {
+
+	id := c.Param("id")
+
+	walletType, err := h.walletTypeService.GetWalletType(c, id)
+	if err != nil {
+		sendWalletTypeError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", walletType)
+
+}
+
+func (h *WalletTypeHandler) UpdateWalletType(c *gin.Context) {
+
+	id := c.Param("id")
+
+	var req UpdateWalletTypeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+		return
+	}
+
+	err := h.walletTypeService.UpdateWalletType(c, id, &req)
+	if err != nil {
+		sendWalletTypeError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", nil)
+
+}
+
+func (h *WalletTypeHandler) DeleteWalletType(c *gin.Context) {
+
+	id := c.Param("id")
+
+	err := h.walletTypeService.DeleteWalletType(c, id)
+	if err != nil {
+		sendWalletTypeError(c, err)
+		return
+	}
+
+	helper.SendSuccess(c, http.StatusOK, "success", nil)
+
+}
+
+func sendWalletTypeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrWalletTypeExists):
+		helper.SendError(c, http.StatusConflict, err, helper.ErrInvalidRequest)
+	case errors.Is(err, ErrWalletTypeNotFound):
+		helper.SendError(c, http.StatusNotFound, err, helper.ErrInvalidRequest)
+	default:
+		helper.SendError(c, http.StatusBadRequest, err, helper.ErrInvalidRequest)
+	}
+}
@@ -0,0 +1,34 @@
+package wallettype
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WalletType is one entry of the wallet catalog. Every user gets a wallet of
+// each active type; Name is what wallets, ledger entries and ledger accounts
+// refer to, so it cannot change once created.
+type WalletType struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name     string             `bson:"name" json:"name"`
+	Currency string             `bson:"currency" json:"currency"`
+	// SpendableOn lists the purchase purposes the wallet can pay for, such
+	// as "store" or "service".
+	SpendableOn []string `bson:"spendable_on" json:"spendable_on"`
+	// AllowNegative lets purchases take the balance below zero.
+	AllowNegative bool      `bson:"allow_negative" json:"allow_negative"`
+	Active        bool      `bson:"active" json:"active"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// CanSpendOn reports whether the wallet can pay for purpose.
+func (t *WalletType) CanSpendOn(purpose string) bool {
+	for _, p := range t.SpendableOn {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package wallettype
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WalletTypeRepository interface {
+	CreateWalletType(ctx context.Context, walletType *WalletType) error
+	GetWalletTypes(ctx context.Context, filter bson.M) ([]*WalletType, error)
+	GetWalletType(ctx context.Context, id primitive.ObjectID) (*WalletType, error)
+	GetWalletTypeByName(ctx context.Context, name string) (*WalletType, error)
+	UpdateWalletType(ctx context.Context, id primitive.ObjectID, walletType bson.M) error
+	DeleteWalletType(ctx context.Context, id primitive.ObjectID) error
+	SeedWalletTypes(ctx context.Context, walletTypes []*WalletType) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type walletTypeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewWalletTypeRepository(collection *mongo.Collection) WalletTypeRepository {
+	return &walletTypeRepository{
+		collection: collection,
+	}
+}
+
+func (r *walletTypeRepository) CreateWalletType(ctx context.Context, walletType *WalletType) error {
+	_, err := r.collection.InsertOne(ctx, walletType)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrWalletTypeExists
+	}
+	return err
+}
+
+func (r *walletTypeRepository) GetWalletTypes(ctx context.Context, filter bson.M) ([]*WalletType, error) {
+
+	var walletTypes []*WalletType
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &walletTypes); err != nil {
+		return nil, err
+	}
+
+	return walletTypes, nil
+}
+
+func (r *walletTypeRepository) GetWalletType(ctx context.Context, id primitive.ObjectID) (*WalletType, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *walletTypeRepository) GetWalletTypeByName(ctx context.Context, name string) (*WalletType, error) {
+	return r.findOne(ctx, bson.M{"name": name})
+}
+
+func (r *walletTypeRepository) findOne(ctx context.Context, filter bson.M) (*WalletType, error) {
+
+	var walletType WalletType
+
+	err := r.collection.FindOne(ctx, filter).Decode(&walletType)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWalletTypeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletType, nil
+}
+
+func (r *walletTypeRepository) UpdateWalletType(ctx context.Context, id primitive.ObjectID, walletType bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": walletType})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrWalletTypeNotFound
+	}
+	return nil
+}
+
+func (r *walletTypeRepository) DeleteWalletType(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrWalletTypeNotFound
+	}
+	return nil
+}
+
+// SeedWalletTypes fills an empty catalog. Once any wallet type exists the
+// catalog is left alone, so types an admin deleted are not brought back.
+func (r *walletTypeRepository) SeedWalletTypes(ctx context.Context, walletTypes []*WalletType) error {
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, walletType := range walletTypes {
+		if err := r.CreateWalletType(ctx, walletType); err != nil && err != ErrWalletTypeExists {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *walletTypeRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,17 @@
+package wallettype
+
+type CreateWalletTypeRequest struct {
+	Name          string   `json:"name"`
+	Currency      string   `json:"currency"`
+	SpendableOn   []string `json:"spendable_on"`
+	AllowNegative bool     `json:"allow_negative"`
+	Active        bool     `json:"active"`
+}
+
+// UpdateWalletTypeRequest has no currency: wallets keep the currency they
+// were created with, so it cannot change under them.
+type UpdateWalletTypeRequest struct {
+	SpendableOn   *[]string `json:"spendable_on"`
+	AllowNegative *bool     `json:"allow_negative"`
+	Active        *bool     `json:"active"`
+}
@@ -0,0 +1,18 @@
+package wallettype
+
+import (
+	"wallet-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(r *gin.Engine, handler *WalletTypeHandler) {
+	walletTypeGroup := r.Group("/api/v1/wallet_types")
+	{
+		walletTypeGroup.POST("", middleware.Secured(), middleware.Authorize(middleware.PermWalletTypeWrite), handler.CreateWalletType)
+		walletTypeGroup.GET("", handler.GetAllWalletType)
+		walletTypeGroup.GET("/:id", handler.GetWalletType)
+		walletTypeGroup.PUT("/:id", middleware.Secured(), middleware.Authorize(middleware.PermWalletTypeWrite), handler.UpdateWalletType)
+		walletTypeGroup.DELETE("/:id", middleware.Secured(), middleware.Authorize(middleware.PermWalletTypeWrite), handler.DeleteWalletType)
+	}
+}
@@ -0,0 +1,155 @@
+package wallettype
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// namePattern keeps names safe to embed in ledger account names such as
+// "wallet:<user>:<type>".
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type WalletTypeService interface {
+	CreateWalletType(ctx context.Context, req *CreateWalletTypeRequest) error
+	GetAllWalletType(ctx context.Context) ([]*WalletType, error)
+	GetActiveWalletTypes(ctx context.Context) ([]*WalletType, error)
+	GetWalletType(ctx context.Context, id string) (*WalletType, error)
+	GetWalletTypeByName(ctx context.Context, name string) (*WalletType, error)
+	UpdateWalletType(ctx context.Context, id string, req *UpdateWalletTypeRequest) error
+	DeleteWalletType(ctx context.Context, id string) error
+}
+
+type walletTypeService struct {
+	walletTypeRepo WalletTypeRepository
+}
+
+func NewWalletTypeService(walletTypeRepo WalletTypeRepository) WalletTypeService {
+	return &walletTypeService{
+		walletTypeRepo: walletTypeRepo,
+	}
+}
+
+// Defaults are the wallet types every deployment started with, seeded into
+// an empty catalog.
+func Defaults(currency string) []*WalletType {
+	now := time.Now()
+	return []*WalletType{
+		{ID: primitive.NewObjectID(), Name: "store", Currency: currency, SpendableOn: []string{"store"}, Active: true, CreatedAt: now, UpdatedAt: now},
+		{ID: primitive.NewObjectID(), Name: "service", Currency: currency, SpendableOn: []string{"service"}, Active: true, CreatedAt: now, UpdatedAt: now},
+	}
+}
+
+func (s *walletTypeService) CreateWalletType(ctx context.Context, req *CreateWalletTypeRequest) error {
+
+	if !namePattern.MatchString(req.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits and underscores")
+	}
+
+	if req.Currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+
+	walletType := &WalletType{
+		ID:            primitive.NewObjectID(),
+		Name:          req.Name,
+		Currency:      req.Currency,
+		SpendableOn:   req.SpendableOn,
+		AllowNegative: req.AllowNegative,
+		Active:        req.Active,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	return s.walletTypeRepo.CreateWalletType(ctx, walletType)
+}
+
+func (s *walletTypeService) GetAllWalletType(ctx context.Context) ([]*WalletType, error) {
+	return s.walletTypeRepo.GetWalletTypes(ctx, bson.M{})
+}
+
+func (s *walletTypeService) GetActiveWalletTypes(ctx context.Context) ([]*WalletType, error) {
+	return s.walletTypeRepo.GetWalletTypes(ctx, bson.M{"active": true})
+}
+
+func (s *walletTypeService) GetWalletType(ctx context.Context, id string) (*WalletType, error) {
+
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.walletTypeRepo.GetWalletType(ctx, objectID)
+}
+
+func (s *walletTypeService) GetWalletTypeByName(ctx context.Context, name string) (*WalletType, error) {
+
+	if name == "" {
+		return nil, fmt.Errorf("wallet_type is required")
+	}
+
+	walletType, err := s.walletTypeRepo.GetWalletTypeByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("wallet type %s: %w", name, err)
+	}
+
+	return walletType, nil
+}
+
+func (s *walletTypeService) UpdateWalletType(ctx context.Context, id string, req *UpdateWalletTypeRequest) error {
+
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	updateWalletType := bson.M{}
+
+	if req.SpendableOn != nil {
+		updateWalletType["spendable_on"] = *req.SpendableOn
+	}
+
+	if req.AllowNegative != nil {
+		updateWalletType["allow_negative"] = *req.AllowNegative
+	}
+
+	if req.Active != nil {
+		updateWalletType["active"] = *req.Active
+	}
+
+	updateWalletType["updated_at"] = time.Now()
+
+	if len(updateWalletType) == 1 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	return s.walletTypeRepo.UpdateWalletType(ctx, objectID, updateWalletType)
+}
+
+// DeleteWalletType removes a type from the catalog. Wallets already created
+// with it keep their balance but can no longer be topped up or spent from.
+func (s *walletTypeService) DeleteWalletType(ctx context.Context, id string) error {
+
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	return s.walletTypeRepo.DeleteWalletType(ctx, objectID)
+}
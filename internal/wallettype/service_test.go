@@ -0,0 +1,222 @@
+package wallettype
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestRepository gives the test an indexed catalog in its own database,
+// dropped when the test ends. Tests are skipped when MONGO_TEST_URI is not set.
+func newTestRepository(t *testing.T) WalletTypeRepository {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set; skipping test that needs Mongo")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	db := client.Database(fmt.Sprintf("wallettype_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	repo := NewWalletTypeRepository(db.Collection("wallet_types"))
+	if err := repo.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("wallet type indexes: %v", err)
+	}
+
+	return repo
+}
+
+func TestNamePattern(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "store", want: true},
+		{name: "bonus_points", want: true},
+		{name: "tier2", want: true},
+		{name: "a", want: true},
+		{name: "", want: false},
+		{name: "2tier", want: false},
+		{name: "_bonus", want: false},
+		{name: "Bonus", want: false},
+		{name: "bonus-points", want: false},
+		{name: "bonus points", want: false},
+		{name: "wallet:u1", want: false},
+		{name: "bonus\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := namePattern.MatchString(tt.name); got != tt.want {
+				t.Errorf("namePattern.MatchString(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// Requests are validated before the repository is used, so none is needed.
+func TestCreateWalletTypeValidates(t *testing.T) {
+	s := NewWalletTypeService(nil)
+
+	tests := []struct {
+		name string
+		req  CreateWalletTypeRequest
+	}{
+		{name: "invalid name", req: CreateWalletTypeRequest{Name: "Bonus", Currency: "POINT"}},
+		{name: "missing currency", req: CreateWalletTypeRequest{Name: "bonus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.CreateWalletType(context.Background(), &tt.req); err == nil {
+				t.Error("CreateWalletType accepted the request, want an error")
+			}
+		})
+	}
+}
+
+func TestWalletTypeLifecycle(t *testing.T) {
+	s := NewWalletTypeService(newTestRepository(t))
+	ctx := context.Background()
+
+	req := &CreateWalletTypeRequest{Name: "bonus", Currency: "POINT", SpendableOn: []string{"store"}, Active: true}
+	if err := s.CreateWalletType(ctx, req); err != nil {
+		t.Fatalf("CreateWalletType: %v", err)
+	}
+	if err := s.CreateWalletType(ctx, req); !errors.Is(err, ErrWalletTypeExists) {
+		t.Errorf("second CreateWalletType error = %v, want ErrWalletTypeExists", err)
+	}
+
+	created, err := s.GetWalletTypeByName(ctx, "bonus")
+	if err != nil {
+		t.Fatalf("GetWalletTypeByName: %v", err)
+	}
+	if created.Currency != "POINT" || !created.Active || !created.CanSpendOn("store") {
+		t.Errorf("created wallet type = %+v", created)
+	}
+
+	inactive := false
+	spendableOn := []string{"store", "service"}
+	if err := s.UpdateWalletType(ctx, created.ID.Hex(), &UpdateWalletTypeRequest{SpendableOn: &spendableOn, Active: &inactive}); err != nil {
+		t.Fatalf("UpdateWalletType: %v", err)
+	}
+
+	updated, err := s.GetWalletType(ctx, created.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetWalletType: %v", err)
+	}
+	if updated.Active || !updated.CanSpendOn("service") {
+		t.Errorf("updated wallet type = %+v, want inactive and spendable on service", updated)
+	}
+
+	active, err := s.GetActiveWalletTypes(ctx)
+	if err != nil {
+		t.Fatalf("GetActiveWalletTypes: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("active wallet types = %d, want 0", len(active))
+	}
+
+	if err := s.UpdateWalletType(ctx, created.ID.Hex(), &UpdateWalletTypeRequest{}); err == nil {
+		t.Error("UpdateWalletType accepted an update with no fields")
+	}
+
+	if err := s.DeleteWalletType(ctx, created.ID.Hex()); err != nil {
+		t.Fatalf("DeleteWalletType: %v", err)
+	}
+	if _, err := s.GetWalletTypeByName(ctx, "bonus"); !errors.Is(err, ErrWalletTypeNotFound) {
+		t.Errorf("GetWalletTypeByName after delete error = %v, want ErrWalletTypeNotFound", err)
+	}
+	if err := s.DeleteWalletType(ctx, created.ID.Hex()); !errors.Is(err, ErrWalletTypeNotFound) {
+		t.Errorf("second DeleteWalletType error = %v, want ErrWalletTypeNotFound", err)
+	}
+}
+
+func TestUpdateWalletTypeKeepsCurrency(t *testing.T) {
+	s := NewWalletTypeService(newTestRepository(t))
+	ctx := context.Background()
+
+	if err := s.CreateWalletType(ctx, &CreateWalletTypeRequest{Name: "bonus", Currency: "POINT", Active: true}); err != nil {
+		t.Fatalf("CreateWalletType: %v", err)
+	}
+	created, err := s.GetWalletTypeByName(ctx, "bonus")
+	if err != nil {
+		t.Fatalf("GetWalletTypeByName: %v", err)
+	}
+
+	// A currency in the body has nowhere to go
+	var req UpdateWalletTypeRequest
+	if err := json.Unmarshal([]byte(`{"currency":"USD","allow_negative":true}`), &req); err != nil {
+		t.Fatalf("decode request: %v", err)
+	}
+	if err := s.UpdateWalletType(ctx, created.ID.Hex(), &req); err != nil {
+		t.Fatalf("UpdateWalletType: %v", err)
+	}
+
+	updated, err := s.GetWalletTypeByName(ctx, "bonus")
+	if err != nil {
+		t.Fatalf("GetWalletTypeByName: %v", err)
+	}
+	if updated.Currency != "POINT" {
+		t.Errorf("currency = %s, want POINT", updated.Currency)
+	}
+	if !updated.AllowNegative {
+		t.Error("allow_negative was not updated")
+	}
+}
+
+func TestSeedWalletTypesOnlyFillsEmptyCatalog(t *testing.T) {
+	r := newTestRepository(t)
+	s := NewWalletTypeService(r)
+	ctx := context.Background()
+
+	if err := r.SeedWalletTypes(ctx, Defaults("POINT")); err != nil {
+		t.Fatalf("SeedWalletTypes: %v", err)
+	}
+
+	seeded, err := s.GetAllWalletType(ctx)
+	if err != nil {
+		t.Fatalf("GetAllWalletType: %v", err)
+	}
+	if len(seeded) != 2 || seeded[0].Name != "service" || seeded[1].Name != "store" {
+		t.Fatalf("seeded wallet types = %v, want service and store", seeded)
+	}
+
+	// A type an admin deleted is not brought back
+	if err := s.DeleteWalletType(ctx, seeded[0].ID.Hex()); err != nil {
+		t.Fatalf("DeleteWalletType: %v", err)
+	}
+	if err := r.SeedWalletTypes(ctx, Defaults("POINT")); err != nil {
+		t.Fatalf("second SeedWalletTypes: %v", err)
+	}
+
+	after, err := s.GetAllWalletType(ctx)
+	if err != nil {
+		t.Fatalf("GetAllWalletType: %v", err)
+	}
+	if len(after) != 1 || after[0].Name != "store" {
+		t.Errorf("wallet types after reseeding = %v, want only store", after)
+	}
+}
@@ -105,7 +105,10 @@ const (
 	MinimumUsageTime = "minimum_usage_time"
 	MaximumUsageTime = "maximum_usage_time"
 
-	UserID = "user_id"
+	UserID  = "user_id"
+	Roles   = "roles"
+	Service = "service"
+	Scopes  = "scopes"
 )
 
 type contextKey string
@@ -71,6 +71,16 @@ func (sd *serviceDiscovery) DiscoverService() (*api.CatalogService, error) {
 	return service, nil
 }
 
+// StatusError - Returned by CallAPI when the service answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("service returned status %d", e.StatusCode)
+}
+
 // CallAPI - Function to send an HTTP request to the discovered service (supports GET, PUT, PATCH, DELETE, POST, etc.).
 func (sd *serviceDiscovery) CallAPI(service *api.CatalogService, endpoint, method string, body []byte, headers map[string]string) (string, error) {
 	// Build the API URL using service address and port
@@ -100,5 +110,9 @@ func (sd *serviceDiscovery) CallAPI(service *api.CatalogService, endpoint, metho
 		return "", fmt.Errorf("failed to read response: %v", err)
 	}
 
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
 	return string(bodyBytes), nil
 }
@@ -0,0 +1,28 @@
+package money
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LegacyFilter matches documents whose field still holds a float value
+// written before amounts were stored as integers.
+func LegacyFilter(field string) bson.M {
+	return bson.M{field: bson.M{"$type": "double"}}
+}
+
+// LegacyUpdate returns an update pipeline that rewrites a float major-unit
+// field to its int64 fixed-point form with the given scale (AmountScale or
+// RateScale). It rounds half away from zero, the same rule as decoding a
+// legacy double, rather than $round's half-to-even.
+func LegacyUpdate(field string, scale int64) bson.A {
+	scaled := bson.M{"$multiply": bson.A{"$" + field, scale}}
+
+	return bson.A{
+		bson.M{"$set": bson.M{
+			field: bson.M{"$toLong": bson.M{"$multiply": bson.A{
+				bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{scaled, 0}}, -1, 1}},
+				bson.M{"$floor": bson.M{"$add": bson.A{bson.M{"$abs": scaled}, 0.5}}},
+			}}},
+		}},
+	}
+}
@@ -0,0 +1,243 @@
+// Package money holds the fixed-point types used for every balance, amount and
+// exchange rate in the service, so no monetary value is ever stored as a float.
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+const (
+	// AmountDecimals is the number of decimal places an Amount carries.
+	AmountDecimals = 2
+	// AmountScale is the number of minor units in one major unit.
+	AmountScale = 100
+
+	// RateDecimals is the number of decimal places a Rate carries.
+	RateDecimals = 6
+	// RateScale is the integer value of a rate of exactly 1.
+	RateScale = 1_000_000
+)
+
+// Amount is a monetary value in minor units (hundredths of the major unit).
+// It is stored in MongoDB as an int64 and rendered in JSON as a decimal
+// number such as 12.34.
+type Amount int64
+
+// Rate is an exchange rate stored with six decimal places. Applying a rate to
+// an Amount rounds half away from zero to the nearest minor unit.
+type Rate int64
+
+// ParseAmount parses a decimal string such as "12.34". More than two decimal
+// places is an error rather than being silently rounded.
+func ParseAmount(s string) (Amount, error) {
+	v, err := parseFixed(s, AmountDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	return Amount(v), nil
+}
+
+// ParseRate parses a decimal string such as "0.000042".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s, RateDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	return Rate(v), nil
+}
+
+func (a Amount) String() string {
+	return formatFixed(int64(a), AmountDecimals)
+}
+
+func (r Rate) String() string {
+	return formatFixed(int64(r), RateDecimals)
+}
+
+// Convert applies the rate to an amount: amount * rate, rounded half away
+// from zero to the nearest minor unit. The product is computed with big
+// integers so large balances cannot overflow mid-calculation.
+func (r Rate) Convert(a Amount) (Amount, error) {
+	return mulDivRound(int64(a), int64(r), RateScale)
+}
+
+// Invert returns an amount expressed in the rate's base currency, i.e.
+// amount / rate, using the same rounding rule as Convert.
+func (r Rate) Invert(a Amount) (Amount, error) {
+	if r <= 0 {
+		return 0, fmt.Errorf("cannot invert non-positive rate %s", r)
+	}
+	return mulDivRound(int64(a), RateScale, int64(r))
+}
+
+func mulDivRound(a, b, d int64) (Amount, error) {
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(d)
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero: bump the quotient when twice the
+	// remainder reaches the divisor.
+	if new(big.Int).Abs(new(big.Int).Lsh(rem, 1)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("amount overflow")
+	}
+	return Amount(q.Int64()), nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := ParseAmount(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := ParseRate(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.Int64, bsoncore.AppendInt64(nil, int64(a)), nil
+}
+
+// UnmarshalBSONValue reads the int64 minor-unit form and also accepts the
+// legacy double and decimal128 major-unit forms written before the migration.
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v, err := decodeFixed(t, data, AmountDecimals)
+	if err != nil {
+		return err
+	}
+	*a = Amount(v)
+	return nil
+}
+
+func (r Rate) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.Int64, bsoncore.AppendInt64(nil, int64(r)), nil
+}
+
+// UnmarshalBSONValue reads the int64 form and the legacy double form.
+func (r *Rate) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v, err := decodeFixed(t, data, RateDecimals)
+	if err != nil {
+		return err
+	}
+	*r = Rate(v)
+	return nil
+}
+
+func decodeFixed(t bsontype.Type, data []byte, decimals int) (int64, error) {
+	val := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Int64:
+		return val.Int64(), nil
+	case bsontype.Int32:
+		return int64(val.Int32()), nil
+	case bsontype.Double:
+		return int64(math.Round(val.Double() * math.Pow10(decimals))), nil
+	case bsontype.Decimal128:
+		return parseFixed(val.Decimal128().String(), decimals)
+	case bsontype.Null:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("cannot decode %s into a fixed-point value", t)
+	}
+}
+
+func parseFixed(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("no digits")
+	}
+	if strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return 0, fmt.Errorf("not a decimal number")
+	}
+
+	// Allow trailing zeros beyond the supported precision, e.g. "1.500".
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > decimals {
+		return 0, fmt.Errorf("more than %d decimal places", decimals)
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	n, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return 0, fmt.Errorf("not a decimal number")
+	}
+	if negative {
+		n.Neg(n)
+	}
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("value out of range")
+	}
+
+	return n.Int64(), nil
+}
+
+func formatFixed(v int64, decimals int) string {
+	sign := ""
+	u := new(big.Int).SetInt64(v)
+	if u.Sign() < 0 {
+		sign = "-"
+		u.Neg(u)
+	}
+
+	digits := u.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	intPart := digits[:len(digits)-decimals]
+	fracPart := strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fracPart == "" {
+		return sign + intPart
+	}
+	return sign + intPart + "." + fracPart
+}
@@ -0,0 +1,182 @@
+package money
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: "12.34", want: 1234},
+		{in: "12", want: 1200},
+		{in: "12.3", want: 1230},
+		{in: "0.01", want: 1},
+		{in: ".5", want: 50},
+		{in: "5.", want: 500},
+		{in: "+1.50", want: 150},
+		{in: "-1.50", want: -150},
+		{in: "-0.01", want: -1},
+		{in: " 7.25 ", want: 725},
+		{in: "1.500", want: 150},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "-92233720368547758.08", want: math.MinInt64},
+		{in: "1.234", wantErr: true},
+		{in: "0.001", wantErr: true},
+		{in: "92233720368547758.08", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "1", want: 1_000_000},
+		{in: "0.000042", want: 42},
+		{in: "25000", want: 25_000_000_000},
+		{in: "1.2345670", want: 1_234_567},
+		{in: "-0.5", want: -500_000},
+		{in: "0.0000001", wantErr: true},
+		{in: "9223372036854.775808", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRate(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRate(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFixed(t *testing.T) {
+	tests := []struct {
+		v        int64
+		decimals int
+		want     string
+	}{
+		{v: 0, decimals: 2, want: "0"},
+		{v: 1, decimals: 2, want: "0.01"},
+		{v: 10, decimals: 2, want: "0.1"},
+		{v: 100, decimals: 2, want: "1"},
+		{v: 1234, decimals: 2, want: "12.34"},
+		{v: -1, decimals: 2, want: "-0.01"},
+		{v: -1234, decimals: 2, want: "-12.34"},
+		{v: 42, decimals: 6, want: "0.000042"},
+		{v: 1_500_000, decimals: 6, want: "1.5"},
+		{v: math.MaxInt64, decimals: 2, want: "92233720368547758.07"},
+		{v: math.MinInt64, decimals: 2, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFixed(tt.v, tt.decimals); got != tt.want {
+			t.Errorf("formatFixed(%d, %d) = %q, want %q", tt.v, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	for _, a := range []Amount{0, 1, -1, 99, 100, 123456, -987654, math.MaxInt64, math.MinInt64} {
+		got, err := ParseAmount(a.String())
+		if err != nil || got != a {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", a.String(), got, err, a)
+		}
+	}
+}
+
+func TestMulDivRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, d int64
+		want    Amount
+		wantErr bool
+	}{
+		{name: "exact", a: 1000, b: 2, d: 1, want: 2000},
+		{name: "below half rounds down", a: 1, b: 4, d: 10, want: 0},
+		{name: "half rounds up", a: 1, b: 5, d: 10, want: 1},
+		{name: "above half rounds up", a: 1, b: 6, d: 10, want: 1},
+		{name: "negative half rounds away from zero", a: -1, b: 5, d: 10, want: -1},
+		{name: "negative below half rounds toward zero", a: -1, b: 4, d: 10, want: 0},
+		{name: "negative divisor", a: 1, b: 5, d: -10, want: -1},
+		{name: "two point five", a: 25, b: 1, d: 10, want: 3},
+		{name: "minus two point five", a: -25, b: 1, d: 10, want: -3},
+		{name: "large product does not overflow", a: math.MaxInt64, b: RateScale, d: RateScale, want: math.MaxInt64},
+		{name: "overflow", a: math.MaxInt64, b: 2, d: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := mulDivRound(tt.a, tt.b, tt.d)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: mulDivRound(%d, %d, %d) = %d, want error", tt.name, tt.a, tt.b, tt.d, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: mulDivRound(%d, %d, %d) error: %v", tt.name, tt.a, tt.b, tt.d, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: mulDivRound(%d, %d, %d) = %d, want %d", tt.name, tt.a, tt.b, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRateConvertAndInvert(t *testing.T) {
+	rate := Rate(1_234_567) // 1.234567
+
+	got, err := rate.Convert(1000) // 10.00 * 1.234567 = 12.34567
+	if err != nil || got != 1235 {
+		t.Errorf("Convert(10.00) = %s, %v; want 12.35", got, err)
+	}
+
+	got, err = rate.Invert(1235) // 12.35 / 1.234567 = 10.0035...
+	if err != nil || got != 1000 {
+		t.Errorf("Invert(12.35) = %s, %v; want 10.00", got, err)
+	}
+
+	if _, err := Rate(0).Invert(100); err == nil {
+		t.Error("Invert with a zero rate succeeded, want error")
+	}
+}